@@ -0,0 +1,46 @@
+package conf
+
+import (
+	"os"
+
+	"github.com/rsb/failure"
+)
+
+// ProcessArgs populates the fields tagged arg:N from the positional args,
+// usually the args cobra hands to RunE. Values are processed like any other
+// value so they get the same coercion and checks as flags. When there is no
+// arg at a field's index the default is used, and a required field fails.
+// Fields without an arg tag are not touched.
+func ProcessArgs(args []string, spec interface{}, prefix ...string) error {
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	var failed *failure.Multi
+	for _, field := range fields {
+		index, ok := field.ArgIndex()
+		if !ok {
+			continue
+		}
+
+		var value string
+		switch {
+		case index < len(args):
+			value = args[index]
+		case field.IsDefault():
+			value = field.DefaultValue()
+		case field.IsRequired():
+			failed = failure.Append(failed, failure.Config("required arg (%d) missing value for (%s)", index, field.Path))
+			continue
+		default:
+			continue
+		}
+
+		if err = processValue(value, nil, field, os.LookupEnv); err != nil {
+			failed = failure.Append(failed, failure.Wrap(err, "ProcessField failed (%s)", field.Path))
+		}
+	}
+
+	return failed.ErrorOrNil()
+}
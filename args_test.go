@@ -0,0 +1,107 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type CopyArgs struct {
+	Source  string `conf:"arg:0,required"`
+	Dest    string `conf:"arg:1,default:."`
+	Retries int    `conf:"arg:2"`
+	Verbose bool   `conf:"env:COPY_VERBOSE,default:true"`
+}
+
+func TestProcessArgs_Success(t *testing.T) {
+	var config CopyArgs
+	err := conf.ProcessArgs([]string{"a.txt", "/tmp", "3"}, &config)
+	require.NoError(t, err)
+
+	assert.Equal(t, "a.txt", config.Source)
+	assert.Equal(t, "/tmp", config.Dest)
+	assert.Equal(t, 3, config.Retries)
+	assert.False(t, config.Verbose, "fields without an arg tag are not touched")
+}
+
+func TestProcessArgs_Defaults(t *testing.T) {
+	var config CopyArgs
+	err := conf.ProcessArgs([]string{"a.txt"}, &config)
+	require.NoError(t, err)
+
+	assert.Equal(t, "a.txt", config.Source)
+	assert.Equal(t, ".", config.Dest)
+	assert.Equal(t, 0, config.Retries)
+}
+
+func TestProcessArgs_Failure(t *testing.T) {
+	var config CopyArgs
+	err := conf.ProcessArgs(nil, &config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required arg (0) missing value for (Source)")
+
+	err = conf.ProcessArgs([]string{"a.txt", "/tmp", "three"}, &config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ProcessField failed (Retries)")
+}
+
+func TestProcessArgs_WithCLIAndEnv(t *testing.T) {
+	type MyConfig struct {
+		Source  string `conf:"arg:0,required"`
+		Dest    string `conf:"env:COPY_DEST,arg:1,required"`
+		Retries int    `conf:"env:COPY_RETRIES,cli:retries,default:1"`
+		Verbose bool   `conf:"env:COPY_VERBOSE,cli:verbose"`
+	}
+
+	os.Clearenv()
+	setenv(t, "COPY_VERBOSE", "true")
+
+	var config MyConfig
+	cmd := &cobra.Command{
+		Use: "copy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := conf.ProcessEnv(&config); err != nil {
+				return err
+			}
+			if err := conf.ProcessCLI(cmd, viper.New(), &config); err != nil {
+				return err
+			}
+			return conf.ProcessArgs(args, &config)
+		},
+	}
+
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err, "conf.BindCLI is not expected to fail")
+
+	cmd.SetArgs([]string{"--retries", "3", "a.txt", "/tmp"})
+	err = cmd.Execute()
+	require.NoError(t, err, "cmd.Execute is not expected to fail")
+
+	expected := MyConfig{Source: "a.txt", Dest: "/tmp", Retries: 3, Verbose: true}
+	assert.Equal(t, expected, config)
+	os.Clearenv()
+}
+
+func TestConfig_Reprocess_SkipsArgs(t *testing.T) {
+	type MyConfig struct {
+		Dest    string `conf:"env:COPY_DEST,arg:0"`
+		Retries int    `conf:"env:COPY_RETRIES,default:1"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	require.NoError(t, conf.ProcessArgs([]string{"/tmp"}, &config), "conf.ProcessArgs is not expected to fail")
+
+	setenv(t, "COPY_DEST", "/var")
+	setenv(t, "COPY_RETRIES", "3")
+	require.NoError(t, c.Reprocess(), "c.Reprocess is not expected to fail")
+	assert.Equal(t, MyConfig{Dest: "/tmp", Retries: 3}, config, "arg fields are left alone")
+	os.Clearenv()
+}
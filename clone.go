@@ -0,0 +1,96 @@
+package conf
+
+import "reflect"
+
+// Clone returns a copy of the Config with its own copy of Data, so
+// processing one never writes into the other. Data must be a pointer to a
+// struct. The copy is deep for exported fields, pointers, slices and maps
+// included, while unexported fields are copied as they are. Origins are
+// copied as well.
+func (c *Config) Clone() (*Config, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := reflect.ValueOf(c.Data)
+	if s.Kind() != reflect.Ptr || s.IsNil() || s.Elem().Kind() != reflect.Struct {
+		return nil, InvalidSpecFailure
+	}
+
+	data := reflect.New(s.Elem().Type())
+	data.Elem().Set(deepCopy(s.Elem()))
+
+	clone := Config{
+		Data:                data.Interface(),
+		SkipDefault:         c.SkipDefault,
+		Prefix:              c.Prefix,
+		PrefixEnvVar:        c.PrefixEnvVar,
+		PrefixFunc:          c.PrefixFunc,
+		NameCase:            c.NameCase,
+		IncludeExcludedVars: c.IncludeExcludedVars,
+		ViperEnv:            c.ViperEnv,
+		EmptyAsUnset:        c.EmptyAsUnset,
+		Environment:         c.Environment,
+		EnvironmentVar:      c.EnvironmentVar,
+		OnField:             c.OnField,
+		origins:             c.Origins(),
+	}
+
+	if c.ExcludedVars != nil {
+		clone.ExcludedVars = append([]string{}, c.ExcludedVars...)
+	}
+	if c.CamelWords != nil {
+		clone.CamelWords = append([]string{}, c.CamelWords...)
+	}
+
+	return &clone, nil
+}
+
+// deepCopy returns a copy of v that shares no pointers, slices or maps with
+// it, only exported struct fields are followed
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	}
+
+	return v
+}
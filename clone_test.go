@@ -0,0 +1,63 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Clone(t *testing.T) {
+	type DBConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+	type MyConfig struct {
+		DB    *DBConfig         `conf:"prefix:DB"`
+		Hosts []string          `conf:"env:HOSTS"`
+		Tags  map[string]string `conf:"env:TAGS"`
+		Port  int               `conf:"env:PORT"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_DB_HOST", "db.internal")
+	setenv(t, "APP_HOSTS", "a,b")
+	setenv(t, "APP_TAGS", "env:prod")
+	setenv(t, "APP_PORT", "8080")
+
+	config := MyConfig{DB: &DBConfig{}}
+	c := conf.NewConfig(&config, "APP")
+	c.NameCase = conf.NameCaseUpperSnake
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+
+	clone, err := c.Clone()
+	require.NoError(t, err, "c.Clone is not expected to fail")
+	assert.Equal(t, c.Prefix, clone.Prefix)
+	assert.Equal(t, c.NameCase, clone.NameCase)
+	assert.Equal(t, c.Origins(), clone.Origins())
+
+	cloned, ok := clone.Data.(*MyConfig)
+	require.True(t, ok, "clone.Data is expected to be a *MyConfig")
+	assert.Equal(t, config, *cloned)
+
+	cloned.DB.Host = "other"
+	cloned.Hosts[0] = "z"
+	cloned.Tags["env"] = "dev"
+	assert.Equal(t, "db.internal", config.DB.Host)
+	assert.Equal(t, []string{"a", "b"}, config.Hosts)
+	assert.Equal(t, map[string]string{"env": "prod"}, config.Tags)
+
+	setenv(t, "APP_PORT", "9090")
+	require.NoError(t, clone.ProcessEnv(), "clone.ProcessEnv is not expected to fail")
+	assert.Equal(t, 9090, cloned.Port)
+	assert.Equal(t, 8080, config.Port)
+	os.Clearenv()
+}
+
+func TestConfig_CloneInvalidData(t *testing.T) {
+	c := conf.NewConfig(struct{}{})
+	_, err := c.Clone()
+	require.Error(t, err, "c.Clone is expected to fail")
+	assert.ErrorIs(t, err, conf.InvalidSpecFailure)
+}
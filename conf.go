@@ -5,14 +5,19 @@ using structs with annotated tags to control how it is done.
 package conf
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rsb/failure"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -22,6 +27,7 @@ const (
 	AWSRegion                = "AWS_REGION"
 	AppName                  = "APP_NAME"
 	GlobalParamStoreKey      = "global"
+	MaskedValue              = "****"
 )
 
 var excludedVars = []string{
@@ -31,10 +37,75 @@ var excludedVars = []string{
 	AWSLambdaFunctionNameVar,
 }
 
+// Config wraps a spec with the options used by its methods. The methods
+// always use the prefix from GetPrefix, there is no way to pass another one.
 type Config struct {
+	// Data is the spec being configured, it must be a pointer to a struct
 	Data        interface{}
 	SkipDefault bool
 	Prefix      string
+
+	// PrefixEnvVar names an env var, like APP_ENV_PREFIX, whose value is used
+	// as the prefix instead of Prefix when it is set and not empty.
+	PrefixEnvVar string
+
+	// PrefixFunc computes the prefix each time the Config is processed, for
+	// prefixes that depend on the runtime environment. It wins over
+	// PrefixEnvVar and Prefix unless it returns an empty string.
+	PrefixFunc func() (string, error)
+
+	// NameCase derives env names for fields without an env tag, explicit env
+	// tags are never changed. The zero value derives nothing.
+	NameCase NameCase
+
+	// CamelWords are mixed case words, like OAuth or GraphQL, that NameCase
+	// keeps whole instead of splitting at the case change, see
+	// CamelSplitWords
+	CamelWords []string
+
+	// ExcludedVars are the framework variables left out of param store
+	// collection, env names and reports. When it is nil, as in a Config
+	// literal, DefaultExcludedVars is used. Only an empty, non nil list
+	// excludes nothing.
+	ExcludedVars []string
+
+	// IncludeExcludedVars makes EnvToMap and EnvReport keep the ExcludedVars
+	// that are normally left out.
+	IncludeExcludedVars bool
+
+	// ViperEnv makes ProcessCLI and ResolveCLI fall back to everything viper
+	// knows about a key, including BindEnv and AutomaticEnv bindings, rather
+	// than only the config file. The env var of the field is still looked up
+	// directly first.
+	ViperEnv bool
+
+	// EmptyAsUnset treats env vars that are set but empty, like FOO=, as not
+	// set at all, so the default is used or the required check fails. By
+	// default an empty value is a value.
+	EmptyAsUnset bool
+
+	// Environment is the name of the environment being configured, like
+	// production, which enforces the required-env tags that list it. When it
+	// is empty the value of EnvironmentVar is used instead. Without either
+	// required-env is never enforced.
+	//
+	//	SentryDSN string `conf:"env:SENTRY_DSN,required-env:production|staging"`
+	Environment    string
+	EnvironmentVar string
+
+	// OnField is called by ProcessEnv and ProcessCLI for every field with the
+	// raw value and where it came from, before the value is assigned. Values
+	// of mask and no-print fields are passed as MaskedValue. Fields that had
+	// no value from any source are passed with FromNone and an empty value.
+	OnField func(field Field, value string, source ValueSource)
+
+	// mu guards Data during Reprocess, see View
+	mu sync.RWMutex
+
+	// origins is where each field got its value the last time it was
+	// processed, see Origins
+	origins   map[string]ValueSource
+	originsMu sync.Mutex
 }
 
 func NewConfig(d interface{}, prefixOpt ...string) *Config {
@@ -42,27 +113,250 @@ func NewConfig(d interface{}, prefixOpt ...string) *Config {
 	if len(prefixOpt) > 0 && prefixOpt[0] != "" {
 		prefix = prefixOpt[0]
 	}
-	return &Config{Data: d, SkipDefault: true, Prefix: prefix}
+	return &Config{Data: d, SkipDefault: true, Prefix: prefix, ExcludedVars: DefaultExcludedVars()}
+}
+
+// DefaultExcludedVars returns a copy of the framework variables that are
+// excluded when no other list is configured
+func DefaultExcludedVars() []string {
+	result := make([]string, len(excludedVars))
+	copy(result, excludedVars)
+	return result
 }
 
+// GetPrefix is the prefix the Config is processed with. When PrefixFunc
+// fails the static prefix is returned, the process methods report the error.
 func (c *Config) GetPrefix() string {
-	return c.Prefix
+	prefix, err := c.resolvePrefix()
+	if err != nil {
+		return c.Prefix
+	}
+
+	return prefix
 }
 
 func (c *Config) SetPrefix(prefix string) {
 	c.Prefix = prefix
 }
 
+// WithPrefixFunc sets PrefixFunc and returns c, so it can be chained off
+// NewConfig
+func (c *Config) WithPrefixFunc(fn func() (string, error)) *Config {
+	c.PrefixFunc = fn
+	return c
+}
+
+func (c *Config) resolvePrefix() (string, error) {
+	if c.PrefixFunc != nil {
+		prefix, err := c.PrefixFunc()
+		if err != nil {
+			return "", failure.Wrap(err, "PrefixFunc failed")
+		}
+
+		if prefix != "" {
+			return prefix, nil
+		}
+	}
+
+	if c.PrefixEnvVar != "" {
+		if prefix := os.Getenv(c.PrefixEnvVar); prefix != "" {
+			return prefix, nil
+		}
+	}
+
+	return c.Prefix, nil
+}
+
 func (c *Config) IsPrefixEnabled() bool {
-	return c.Prefix != ""
+	return c.GetPrefix() != ""
+}
+
+// loadPrefix returns the prefix as the variadic argument taken by the free
+// functions, empty when there is no prefix and a single item otherwise.
+func (c *Config) loadPrefix() ([]string, error) {
+	prefix, err := c.resolvePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == "" {
+		return []string{}, nil
+	}
+
+	return []string{prefix}, nil
+}
+
+// withPrefix calls fn with the prefix from loadPrefix, it is how the Config
+// methods run the free function behind them. A failure of fn is wrapped with
+// name, the name of the method.
+func withPrefix[T any](c *Config, name string, fn func(prefix []string) (T, error)) (T, error) {
+	var zero T
+	prefix, err := c.loadPrefix()
+	if err != nil {
+		return zero, failure.Wrap(err, "loadPrefix failed")
+	}
+
+	result, err := fn(prefix)
+	if err != nil {
+		return zero, failure.Wrap(err, "%s failed", name)
+	}
+
+	return result, nil
+}
+
+// run is withPrefix for methods that only return an error
+func (c *Config) run(name string, fn func(prefix []string) error) error {
+	_, err := withPrefix(c, name, func(prefix []string) (struct{}, error) {
+		return struct{}{}, fn(prefix)
+	})
+
+	return err
+}
+
+// options carries the Config settings down to the functions behind its
+// methods, the free functions use defaultOptions
+type options struct {
+	excluded   []string
+	nameCase   NameCase
+	camelWords []string
+
+	// record, when set, is told where each processed field got its value
+	record func(field Field, src ValueSource)
+
+	// lookup replaces os.LookupEnv in ProcessEnv, see ProcessMap
+	lookup func(key string) (string, bool)
+
+	// viperEnv uses v.Get rather than only the config file, see
+	// Config.ViperEnv
+	viperEnv bool
+
+	// emptyAsUnset, see Config.EmptyAsUnset
+	emptyAsUnset bool
+
+	// environment is matched against the required-env tag, see
+	// Config.Environment
+	environment string
+
+	// requireAll treats every field as required, see ProcessEnvStrict
+	requireAll bool
+
+	// onField, see Config.OnField
+	onField func(field Field, value string, source ValueSource)
+
+	// only limits Process to the fields it accepts. Validate is skipped
+	// since the rest of spec has not been populated.
+	only func(field Field) bool
+
+	// structDefault is the value of the field in the defaults struct given
+	// to ProcessEnvWithDefaults, see DefaultsPolicy
+	structDefault func(field Field) (reflect.Value, bool)
+	structFirst   bool
+}
+
+func defaultOptions() options {
+	return options{excluded: excludedVars}
+}
+
+func (c *Config) options() options {
+	excluded := c.ExcludedVars
+	if excluded == nil {
+		excluded = excludedVars
+	}
+
+	return options{
+		excluded:     excluded,
+		nameCase:     c.NameCase,
+		camelWords:   c.CamelWords,
+		record:       c.recordOrigin,
+		viperEnv:     c.ViperEnv,
+		emptyAsUnset: c.EmptyAsUnset,
+		environment:  c.environment(),
+		onField:      c.OnField,
+	}
+}
+
+// environment is Environment or, when that is empty, the value of
+// EnvironmentVar
+func (c *Config) environment() string {
+	if c.Environment != "" || c.EnvironmentVar == "" {
+		return c.Environment
+	}
+
+	return os.Getenv(c.EnvironmentVar)
+}
+
+// lookupEnv is the lookup option, os.LookupEnv when it is not set
+func (o options) lookupEnv() func(key string) (string, bool) {
+	if o.lookup == nil {
+		return os.LookupEnv
+	}
+
+	return o.lookup
+}
+
+// lookupField is lookupEnv using the lookup and emptyAsUnset options. When
+// the value comes from the numbered variables of an indexed field items
+// holds them and value has them joined, for reporting only.
+func (o options) lookupField(field Field) (value string, items []string, ok bool, err error) {
+	lookup := o.lookupEnv()
+
+	if o.emptyAsUnset {
+		next := lookup
+		lookup = func(key string) (string, bool) {
+			value, ok := next(key)
+			return value, ok && value != ""
+		}
+	}
+
+	value, ok, err = lookupVar(field, lookup)
+	if ok || err != nil || !field.IsIndexed() {
+		return value, nil, ok, err
+	}
+
+	if items, ok = lookupIndexed(field, lookup); ok {
+		value = strings.Join(items, field.SliceDelimiter())
+	}
+
+	return value, items, ok, nil
+}
+
+func (o options) lookupStructDefault(field Field) (reflect.Value, bool) {
+	if o.structDefault == nil {
+		return reflect.Value{}, false
+	}
+
+	return o.structDefault(field)
+}
+
+func (o options) recordOrigin(field Field, src ValueSource) {
+	if o.record != nil {
+		o.record(field, src)
+	}
+}
+
+// notifyField calls onField, when set, with the value of masked and
+// no-print fields redacted
+func (o options) notifyField(field Field, value string, src ValueSource) {
+	if o.onField == nil {
+		return
+	}
+
+	if (field.IsMasked() || field.IsNoPrint()) && value != "" {
+		value = MaskedValue
+	}
+
+	o.onField(field, value, src)
 }
 
-func (c *Config) loadPrefix() []string {
-	if !c.IsPrefixEnabled() {
-		return []string{}
+// reportOptions are the options for EnvToMap and EnvReport, which keep the
+// excluded vars when IncludeExcludedVars is set
+func (c *Config) reportOptions() options {
+	opts := c.options()
+	if c.IncludeExcludedVars {
+		opts.excluded = nil
 	}
 
-	return []string{c.GetPrefix()}
+	return opts
 }
 
 func (c *Config) MarkDefaultsAsExcluded() {
@@ -82,80 +376,211 @@ func (c *Config) IsDefaultsExcluded() bool {
 }
 
 func (c *Config) ProcessCLI(cmd *cobra.Command, v *viper.Viper) error {
-	if err := ProcessCLI(cmd, v, c.Data, c.loadPrefix()...); err != nil {
-		return failure.Wrap(err, "ProcessCLI failed")
-	}
-
-	return nil
+	return c.run("ProcessCLI", func(prefix []string) error {
+		return processCLI(cmd, v, c.Data, c.options(), prefix...)
+	})
 }
 
 func (c *Config) ProcessEnv() error {
-	if err := ProcessEnv(c.Data, c.loadPrefix()...); err != nil {
-		return failure.Wrap(err, "ProcessEnv failed")
-	}
+	return c.run("ProcessEnv", func(prefix []string) error {
+		return processEnv(c.Data, c.options(), prefix...)
+	})
+}
 
-	return nil
+// ProcessEnvFields is the package level ProcessEnvFields using the prefix
+// and options of the Config
+func (c *Config) ProcessEnvFields(names ...string) error {
+	return c.run("ProcessEnvFields", func(prefix []string) error {
+		return processEnvFields(c.Data, names, c.options(), prefix...)
+	})
 }
 
-func (c *Config) CollectParamsFromEnv(appTitle string) (map[string]string, error) {
-	result, err := CollectParamsFromEnv(appTitle, c.Data, c.SkipDefault, c.loadPrefix()...)
-	if err != nil {
-		return nil, failure.Wrap(err, "CollectParamsFromEnv failed")
-	}
+// ProcessEnvStrict is ProcessEnv with every field treated as required, see
+// the package level ProcessEnvStrict
+func (c *Config) ProcessEnvStrict() error {
+	return c.run("ProcessEnvStrict", func(prefix []string) error {
+		opts := c.options()
+		opts.requireAll = true
+		return processEnv(c.Data, opts, prefix...)
+	})
+}
 
-	return result, nil
+func (c *Config) ResolveCLI(cmd *cobra.Command, v *viper.Viper) (map[string]Resolution, error) {
+	return withPrefix(c, "ResolveCLI", func(prefix []string) (map[string]Resolution, error) {
+		return resolveCLIFields(cmd, v, c.Data, c.options(), prefix...)
+	})
 }
 
-func (c *Config) ParamNames(appTitle string) ([]string, error) {
-	name, err := ParamNames(appTitle, c.Data, c.IsDefaultsExcluded(), c.loadPrefix()...)
-	if err != nil {
-		return nil, failure.Wrap(err, "EnvNames failed")
-	}
+func (c *Config) Process(ctx context.Context, sources ...Source) error {
+	return c.run("Process", func(prefix []string) error {
+		return process(ctx, c.Data, sources, c.options(), prefix...)
+	})
+}
+
+func (c *Config) ProcessParamStore(ctx context.Context, ps *PStore, appTitle string) error {
+	return c.run("ProcessParamStore", func(prefix []string) error {
+		return processParamStore(ctx, ps, appTitle, c.Data, c.options(), prefix...)
+	})
+}
+
+func (c *Config) CollectParamsFromEnv(appTitle string) (map[string]string, error) {
+	return withPrefix(c, "CollectParamsFromEnv", func(prefix []string) (map[string]string, error) {
+		return collectParamsFromEnv(appTitle, c.Data, c.SkipDefault, c.options(), prefix...)
+	})
+}
 
-	return name, nil
+func (c *Config) ParamNames(appTitle string) ([]string, error) {
+	return withPrefix(c, "ParamNames", func(prefix []string) ([]string, error) {
+		return paramNames(appTitle, c.Data, c.IsDefaultsExcluded(), c.options(), prefix...)
+	})
 }
 
 func (c *Config) EnvNames() ([]string, error) {
-	name, err := EnvNames(c.Data, c.loadPrefix()...)
-	if err != nil {
-		return nil, failure.Wrap(err, "EnvNames failed")
-	}
+	return withPrefix(c, "EnvNames", func(prefix []string) ([]string, error) {
+		return envNames(c.Data, c.options(), false, prefix...)
+	})
+}
+
+func (c *Config) EnvNamesWithAliases() ([]string, error) {
+	return withPrefix(c, "EnvNamesWithAliases", func(prefix []string) ([]string, error) {
+		return envNames(c.Data, c.options(), true, prefix...)
+	})
+}
+
+func (c *Config) EnvNameMap() (map[string]EnvName, error) {
+	return withPrefix(c, "EnvNameMap", func(prefix []string) (map[string]EnvName, error) {
+		return envNameMap(c.Data, c.options(), prefix...)
+	})
+}
+
+// UnknownEnvVars is UnknownEnvVars using the prefix and NameCase of the
+// Config
+func (c *Config) UnknownEnvVars() ([]string, error) {
+	return withPrefix(c, "UnknownEnvVars", func(prefix []string) ([]string, error) {
+		var p string
+		if len(prefix) > 0 {
+			p = prefix[0]
+		}
 
-	return name, nil
+		return unknownEnvVars(c.Data, c.options(), p)
+	})
 }
 
 func (c *Config) EnvToMap() (map[string]string, error) {
-	result, err := EnvToMap(c.Data, c.loadPrefix()...)
-	if err != nil {
-		return nil, failure.Wrap(err, "EnvToMap failed")
-	}
+	return withPrefix(c, "EnvToMap", func(prefix []string) (map[string]string, error) {
+		return envToMap(c.Data, c.reportOptions(), prefix...)
+	})
+}
 
-	return result, nil
+func (c *Config) EnvToMapFiltered(pred func(Field) bool) (map[string]string, error) {
+	return withPrefix(c, "EnvToMapFiltered", func(prefix []string) (map[string]string, error) {
+		opts := c.reportOptions()
+		opts.only = pred
+		return envToMap(c.Data, opts, prefix...)
+	})
 }
 
 func (c *Config) EnvReport() (map[string]string, error) {
-	result, err := EnvReport(c.Data, c.loadPrefix()...)
+	return withPrefix(c, "EnvReport", func(prefix []string) (map[string]string, error) {
+		return envReport(c.Data, c.reportOptions(), prefix...)
+	})
+}
+
+func (c *Config) EnvReportMasked() (map[string]string, error) {
+	return withPrefix(c, "EnvReportMasked", func(prefix []string) (map[string]string, error) {
+		return envReportMasked(c.Data, c.reportOptions(), prefix...)
+	})
+}
+
+func (c *Config) EnvReportJSON() ([]byte, error) {
+	return withPrefix(c, "EnvReportJSON", func(prefix []string) ([]byte, error) {
+		return envReportJSON(c.Data, c.reportOptions(), prefix...)
+	})
+}
+
+func (c *Config) DumpEnv(includeSecrets bool) (string, error) {
+	return withPrefix(c, "DumpEnv", func(prefix []string) (string, error) {
+		return dumpEnv(c.Data, includeSecrets, c.reportOptions(), prefix...)
+	})
+}
+
+// String renders the name and current value of every field in Data, one per
+// line, so a Config can be logged safely. Fields tagged mask are shown as
+// MaskedValue and fields tagged no-print are left out.
+func (c *Config) String() string {
+	prefix, err := c.loadPrefix()
 	if err != nil {
-		return nil, failure.Wrap(err, "Report failed")
+		return fmt.Sprintf("conf.Config(%s)", err)
 	}
 
-	return result, nil
+	fields, err := specFields(c.Data, c.options(), prefix...)
+	if err != nil {
+		return fmt.Sprintf("conf.Config(%s)", err)
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		if field.IsNoPrint() {
+			continue
+		}
+
+		value := MaskedValue
+		if !field.IsMasked() {
+			value = fieldValueString(field.ReflectValue)
+		}
+
+		fmt.Fprintf(&b, "%s=%s\n", field.Name, value)
+	}
+
+	return b.String()
+}
+
+// fieldValueString formats the current value of a field, nil pointers are
+// shown as an empty string.
+func fieldValueString(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	return fmt.Sprintf("%v", v.Interface())
 }
 
+// BindCLI registers a flag on cmd for every field with a cli tag and binds it
+// to v. Required fields that have no env var are also marked required with
+// cobra, so a missing flag is reported before the command runs. Required
+// fields with an env var are left to ProcessCLI since the env can supply them.
+// Fields with a scope-cmds tag are only registered when it lists the name of
+// cmd, see BindCLIScope to match on another name.
 func BindCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...string) error {
+	return BindCLIScope(cmd, v, cmd.Name(), spec, prefix...)
+}
+
+// BindCLIScope is BindCLI matching the scope-cmds tag against scope instead
+// of the name of cmd, for commands that share a name or a set of flags.
+// Fields that are not registered still resolve from env and defaults in
+// ProcessCLI, but are not required by it. The tag is scope-cmds rather than
+// cmds since cmds is an alias for cli.
+//
+//	Verbose bool `conf:"cli:verbose,scope-cmds:serve|migrate"`
+func BindCLIScope(cmd *cobra.Command, v *viper.Viper, scope string, spec interface{}, prefix ...string) error {
 	fields, err := Fields(spec, prefix...)
 	if err != nil {
 		return failure.Wrap(err, "Fields failed")
 	}
 
+	var mutexOrder []string
+	mutexGroups := map[string][]string{}
 	for _, field := range fields {
-		if !field.IsCLI() {
+		if !field.IsCLI() || !field.IsCLIFor(scope) {
 			continue
 		}
 
 		flag := field.CLIFlag()
 		short := field.CLIShortFlag()
-		usage := field.CLIUsage()
+		usage := field.flagUsage()
 		defaultValue := field.DefaultValue()
 
 		flagSet := cmd.Flags()
@@ -163,156 +588,596 @@ func BindCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...str
 			flagSet = cmd.PersistentFlags()
 		}
 
-		switch field.ReflectValue.Type().Kind() {
-		case reflect.Bool:
-			if defaultValue == "" {
-				defaultValue = "false"
+		if err = addFlag(flagSet, field, flag, short, usage, defaultValue); err != nil {
+			return failure.Wrap(err, "addFlag failed for (%s)", flag)
+		}
+
+		lookupFlag := flagSet.Lookup(flag)
+		lookupFlag.Hidden = field.IsHiddenFlag()
+		flagID := field.BindName()
+
+		if field.IsRequired() && !hasEnv(field) {
+			if field.IsPersistentFlag() {
+				err = cmd.MarkPersistentFlagRequired(flag)
+			} else {
+				err = cmd.MarkFlagRequired(flag)
 			}
-			dv, err := strconv.ParseBool(defaultValue)
 			if err != nil {
-				return failure.ToSystem(err, "strconv.ParseBool failed")
+				return failure.ToSystem(err, "cmd.MarkFlagRequired failed for (%s)", flag)
 			}
-			if short != "" {
-				flagSet.BoolP(flag, short, dv, usage)
-			} else {
-				flagSet.Bool(flag, dv, usage)
-			}
-		default:
-			if short != "" {
-				flagSet.StringP(flag, short, defaultValue, usage)
-			} else {
-				flagSet.String(flag, defaultValue, usage)
+		}
+
+		if msg := field.CLIDeprecated(); msg != "" {
+			if err = flagSet.MarkDeprecated(flag, msg); err != nil {
+				return failure.ToSystem(err, "flagSet.MarkDeprecated failed for (%s)", flag)
 			}
 		}
 
-		lookupFlag := flagSet.Lookup(flag)
-		flagID := field.BindName()
+		if group := field.CLIGroup(); group != "" {
+			if err = flagSet.SetAnnotation(flag, FlagGroupAnnotation, []string{group}); err != nil {
+				return failure.ToSystem(err, "flagSet.SetAnnotation failed for (%s)", flag)
+			}
+		}
 
 		if err = v.BindPFlag(flagID, lookupFlag); err != nil {
 			return failure.ToSystem(err, "v.BindPFlag failed for (%s)", flag)
 		}
+
+		if group := field.CLIMutexGroup(); group != "" {
+			if _, ok := mutexGroups[group]; !ok {
+				mutexOrder = append(mutexOrder, group)
+			}
+			mutexGroups[group] = append(mutexGroups[group], flag)
+		}
+	}
+
+	// cobra needs every flag in a group registered before it is marked
+	for _, group := range mutexOrder {
+		cmd.MarkFlagsMutuallyExclusive(mutexGroups[group]...)
+	}
+
+	return nil
+}
+
+// hasEnv reports whether the field can be set from an env var
+func hasEnv(field Field) bool {
+	env := field.EnvVariable()
+	return (env != "" && env != "-") || len(field.EnvAliases()) > 0
+}
+
+// addFlag registers the flag with the type of the field so cobra rejects bad
+// values up front. Fields that are decoded by the field itself, or by the
+// size and encoding tags, are registered as strings. Pointer fields use the
+// type they point to, so a *bool is a bool flag.
+func addFlag(flagSet *pflag.FlagSet, field Field, flag, short, usage, defaultValue string) error {
+	typ := field.ReflectValue.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() == reflect.Bool {
+		if defaultValue == "" {
+			defaultValue = "false"
+		}
+		dv, err := ParseBool(defaultValue)
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseBool failed")
+		}
+		flagSet.BoolP(flag, short, dv, usage)
+		return nil
+	}
+
+	if typ.Kind() == reflect.Slice && isTypedFlagElem(typ.Elem(), field) {
+		return addSliceFlag(flagSet, typ.Elem(), field, flag, short, usage, defaultValue)
+	}
+
+	if !isTypedFlag(typ, field) {
+		flagSet.StringP(flag, short, defaultValue, usage)
+		return nil
+	}
+
+	if typ == durationType {
+		var dv time.Duration
+		if defaultValue != "" {
+			var err error
+			if dv, err = time.ParseDuration(defaultValue); err != nil {
+				return failure.ToSystem(err, "time.ParseDuration failed")
+			}
+		}
+		flagSet.DurationP(flag, short, dv, usage)
+		return nil
+	}
+
+	if defaultValue == "" {
+		defaultValue = "0"
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dv, err := strconv.ParseInt(defaultValue, 0, typ.Bits())
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseInt failed")
+		}
+		switch typ.Kind() {
+		case reflect.Int8:
+			flagSet.Int8P(flag, short, int8(dv), usage)
+		case reflect.Int16:
+			flagSet.Int16P(flag, short, int16(dv), usage)
+		case reflect.Int32:
+			flagSet.Int32P(flag, short, int32(dv), usage)
+		case reflect.Int64:
+			flagSet.Int64P(flag, short, dv, usage)
+		default:
+			flagSet.IntP(flag, short, int(dv), usage)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		dv, err := strconv.ParseUint(defaultValue, 0, typ.Bits())
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseUint failed")
+		}
+		switch typ.Kind() {
+		case reflect.Uint8:
+			flagSet.Uint8P(flag, short, uint8(dv), usage)
+		case reflect.Uint16:
+			flagSet.Uint16P(flag, short, uint16(dv), usage)
+		case reflect.Uint32:
+			flagSet.Uint32P(flag, short, uint32(dv), usage)
+		case reflect.Uint64:
+			flagSet.Uint64P(flag, short, dv, usage)
+		default:
+			flagSet.UintP(flag, short, uint(dv), usage)
+		}
+	case reflect.Float32, reflect.Float64:
+		dv, err := strconv.ParseFloat(defaultValue, typ.Bits())
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseFloat failed")
+		}
+		if typ.Kind() == reflect.Float32 {
+			flagSet.Float32P(flag, short, float32(dv), usage)
+		} else {
+			flagSet.Float64P(flag, short, dv, usage)
+		}
+	}
+
+	return nil
+}
+
+// addSliceFlag registers a repeatable flag for []string and []int fields,
+// the default is the tag default split on the slice delimiter
+func addSliceFlag(flagSet *pflag.FlagSet, elem reflect.Type, field Field, flag, short, usage, defaultValue string) error {
+	var items []string
+	if strings.TrimSpace(defaultValue) != "" {
+		items = strings.Split(defaultValue, field.SliceDelimiter())
+	}
+
+	if elem.Kind() == reflect.String {
+		flagSet.StringSliceP(flag, short, items, usage)
+		return nil
+	}
+
+	dv := make([]int, len(items))
+	for i, item := range items {
+		n, err := strconv.ParseInt(strings.TrimSpace(item), 0, 0)
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseInt failed at (%d)", i)
+		}
+		dv[i] = int(n)
 	}
+	flagSet.IntSliceP(flag, short, dv, usage)
 
 	return nil
 }
 
+// isTypedFlag reports whether typ, the type of the field or the type it
+// points to, is a plain number or duration that pflag can parse the same way
+// processField does
+func isTypedFlag(typ reflect.Type, field Field) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+
+	if field.Tag.Size || field.Tag.Base != 0 || field.Tag.BoolInt {
+		return false
+	}
+
+	return isPlainValue(reflect.New(typ).Elem())
+}
+
+// isTypedFlagElem reports whether a slice of elem can be registered as a
+// StringSlice or IntSlice flag
+func isTypedFlagElem(elem reflect.Type, field Field) bool {
+	switch elem.Kind() {
+	case reflect.String:
+	case reflect.Int:
+		if field.Tag.Size || field.Tag.Base != 0 || field.Tag.BoolInt {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return isPlainValue(reflect.New(elem).Elem())
+}
+
+// isPlainValue reports whether v is decoded by processField's kind switch
+// rather than by one of the interfaces it implements
+func isPlainValue(v reflect.Value) bool {
+	return DecoderFrom(v) == nil && SetterFrom(v) == nil && TextUnmarshaler(v) == nil && BinaryUnmarshaler(v) == nil
+}
+
+// flagValue is the value of f in the form processField expects, slice flags
+// are joined with the slice delimiter of the field
+func flagValue(f *pflag.Flag, field Field) string {
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		return strings.Join(sv.GetSlice(), field.SliceDelimiter())
+	}
+
+	return f.Value.String()
+}
+
+// ProcessCLI populates spec from the flags bound by BindCLI. Each field takes
+// the first value found from the flag when it was set, the env var looked up
+// directly with os.LookupEnv, the viper config file and finally the default.
+// Use Config.ViperEnv to also see values from viper's own env bindings.
 func ProcessCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...string) error {
-	fields, err := Fields(spec, prefix...)
+	return processCLI(cmd, v, spec, defaultOptions(), prefix...)
+}
+
+func processCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, opts options, prefix ...string) error {
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return failure.Wrap(err, "Fields failed")
 	}
 
+	// values are resolved for every field before any are processed so that
+	// required-if and required-unless can look at the other fields
 	var failed *failure.Multi
+	var pending []resolvedField
+	resolved := map[string]string{}
 	for _, field := range fields {
-		var value string
-		env := field.EnvVariable()
-		flag := field.CLIFlag()
-		flagID := field.BindName()
-
-		f := cmd.Flags().Lookup(flag)
-		// CLI flag has the highest priority
-		if flag != "" && f != nil && f.Value.String() != "" && f.Changed {
-			value = f.Value.String()
+		if field.IsArg() {
+			continue
+		}
 
-		} else if env != "" {
-			var ok bool
-			if env != "-" {
-				// Env is the 2nd highest priority
-				value, ok = os.LookupEnv(env)
+		res, err := resolveCLI(cmd, v, field, opts)
+		if err != nil {
+			failed = failure.Append(failed, newConfigError(field, ReasonLookup, err))
+			continue
+		}
 
-				if !ok {
-					value, _ = fromViper(v, flagID)
-				}
-			} else {
-				// Env is ignored, but we still need to check inside a config file
-				value, _ = fromViper(v, flagID)
-			}
+		ok := res.Source != FromNone
+		if ok {
+			resolved[field.EnvVariable()] = res.Value
+			resolved[field.EnvVar] = res.Value
 		}
+		pending = append(pending, resolvedField{Field: field, value: res.Value, items: res.items, ok: ok, source: res.Source})
+	}
 
-		// This will not happen if you use BindCLI because the default value is
-		// always set. It is here just in case you are doing things manually
-		if value == "" {
-			if field.IsDefault() {
-				value = field.DefaultValue()
-			} else {
-				if field.IsRequired() {
-					failed = failure.Append(failed, failure.Config("required key (field:%s,env:%s,cli:%s) missing value", field.Name, env, flag))
-					continue
-				}
+	for _, rf := range pending {
+		field := rf.Field
+		opts.recordOrigin(field, rf.source)
+		opts.notifyField(field, rf.value, rf.source)
+		if !rf.ok {
+			// a flag the scope-cmds tag kept off cmd is not required there
+			scoped := len(field.Tag.ScopeCmds) > 0 && cmd.Flag(field.CLIFlag()) == nil
+			key := fmt.Sprintf("field:%s,env:%s,cli:%s", field.Path, field.EnvVariable(), field.CLIFlag())
+			if err = requiredFailure(field, key, resolved, opts); err != nil && !scoped {
+				failed = failure.Append(failed, newConfigError(field, ReasonMissing, err))
 			}
+			// nothing provided a value, leave the field alone so optional
+			// pointer fields stay nil instead of pointing at a zero value
+			continue
 		}
 
-		if err = ProcessField(value, field.ReflectValue); err != nil {
-			err = failure.Wrap(err, "ProcessField failed (%s)", field.Name)
-			failed = failure.Append(failed, err)
+		if err = processValue(rf.value, rf.items, field, opts.lookupEnv()); err != nil {
+			err = failure.Wrap(err, "ProcessField failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonInvalid, err))
 			continue
 		}
 	}
 
-	return failed.ErrorOrNil()
-}
+	if err = failed.ErrorOrNil(); err != nil {
+		return err
+	}
 
-func fromViper(v *viper.Viper, flagID string) (string, bool) {
-	var value string
-	var found bool
+	return Validate(spec)
+}
 
-	if v.InConfig(flagID) {
-		found = true
-		data := v.Get(flagID)
-		switch d := data.(type) {
-		case map[string]interface{}:
-			for k, v := range d {
-				value += fmt.Sprintf("%s:%s,", k, v)
+// processValue processes value into the field, or items when they are set
+// by an indexed lookup. The expand tag resolves variables with lookup, once
+// for the whole value so slice items and map pairs are not expanded again.
+func processValue(value string, items []string, field Field, lookup func(key string) (string, bool)) error {
+	if field.Tag.Expand {
+		var err error
+		if value, err = expandValue(value, field, lookup); err != nil {
+			return err
+		}
+		for i := range items {
+			if items[i], err = expandValue(items[i], field, lookup); err != nil {
+				return err
 			}
-			value = strings.TrimRight(value, ",")
-		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-			value = fmt.Sprintf("%d", d)
-		case float32, float64:
-			value = fmt.Sprintf("%f", d)
-		case string:
-			value = fmt.Sprintf("%s", d)
-		case bool:
-			value = fmt.Sprintf("%t", d)
-		default:
-			value = fmt.Sprintf("%v", d)
 		}
 	}
 
-	return value, found
+	if items != nil {
+		return processItems(items, field.ReflectValue, field)
+	}
+
+	return processField(value, field.ReflectValue, field)
+}
+
+// fromViper is the value of the field in the viper config file, formatted by
+// stringifyValue so lists and maps round trip through processField
+func fromViper(v *viper.Viper, field Field) (string, bool) {
+	if !v.InConfig(field.BindName()) {
+		return "", false
+	}
+
+	return fromViperAll(v, field)
+}
+
+// fromViperAll is fromViper for any value viper has for the key, such as
+// one from BindEnv or AutomaticEnv, not just one from the config file. Flag
+// defaults do not count.
+func fromViperAll(v *viper.Viper, field Field) (string, bool) {
+	flagID := field.BindName()
+	if !v.IsSet(flagID) {
+		return "", false
+	}
+
+	return stringifyValue(v.Get(flagID), field), true
 }
 
+// ProcessEnv populates spec from the environment. A field whose env var is
+// not set falls back to its default, when it has neither it is left untouched.
+// For pointer fields this means a nil pointer stays nil unless a value or
+// default exists, which lets *T fields tell "unset" apart from the zero value.
+// A default may reference other fields, default:${BASE_DIR}/logs uses the
+// value BASE_DIR resolved to.
 func ProcessEnv(spec interface{}, prefix ...string) error {
-	fields, err := Fields(spec, prefix...)
+	return processEnv(spec, defaultOptions(), prefix...)
+}
+
+// ProcessEnvStrict is ProcessEnv for locked down environments where every
+// field must be given a value explicitly. A field with neither an env var
+// nor a default fails the same way a required one does, instead of being
+// left at its zero value.
+func ProcessEnvStrict(spec interface{}, prefix ...string) error {
+	opts := defaultOptions()
+	opts.requireAll = true
+
+	return processEnv(spec, opts, prefix...)
+}
+
+// ProcessMap is ProcessEnv with the variables taken from values instead of
+// the process environment, which makes it safe for parallel tests. The keys
+// are the full env names, prefix included.
+func ProcessMap(values map[string]string, spec interface{}, prefix ...string) error {
+	opts := defaultOptions()
+	opts.lookup = func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+
+	return processEnv(spec, opts, prefix...)
+}
+
+func processEnv(spec interface{}, opts options, prefix ...string) error {
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return failure.Wrap(err, "Fields failed")
 	}
 
+	// values are resolved for every field before any are processed so that
+	// required-if and required-unless can look at the other fields
+	var failed *failure.Multi
+	var pending []resolvedField
+	resolved := map[string]string{}
 	for _, field := range fields {
+		if field.IsArg() {
+			continue
+		}
+
+		// fields left out by only are still resolved for the conditions and
+		// default references of the others, but never fail or get set
+		skip := opts.only != nil && !opts.only(field)
 		env := field.EnvVariable()
 		if env == "" {
-			return failure.System("env: is required but empty for (%s)", field.Name)
+			if !skip {
+				failed = failure.Append(failed, failure.System("env: is required but empty for (%s)", field.Path))
+			}
+			continue
 		}
 
-		value, ok := os.LookupEnv(env)
-		if !ok && field.IsDefault() {
-			value = field.DefaultValue()
+		value, items, ok, err := opts.lookupField(field)
+		if err != nil {
+			if !skip {
+				err = failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
+				failed = failure.Append(failed, newConfigError(field, ReasonLookup, err))
+			}
+			continue
 		}
 
-		if !ok && !field.IsDefault() {
-			if field.IsRequired() {
-				return failure.Config("required key (%s,%s) missing value", field.Name, env)
+		source := FromNone
+		var preset reflect.Value
+		if ok {
+			source = FromEnv
+		} else if dv, found := opts.lookupStructDefault(field); found && (opts.structFirst || !field.IsDefault()) {
+			preset, value, ok, source = dv, fieldValueString(dv), true, FromDefault
+		} else if field.IsDefault() {
+			value, ok, source = field.DefaultValue(), true, FromDefault
+		}
+
+		if ok {
+			resolved[env] = value
+			resolved[field.EnvVar] = value
+		}
+		pending = append(pending, resolvedField{Field: field, value: value, items: items, ok: ok, source: source, preset: preset, skip: skip})
+	}
+
+	if err = resolveDefaultRefs(pending, resolved); err != nil {
+		failed = failure.Append(failed, failure.Wrap(err, "resolveDefaultRefs failed"))
+	}
+
+	for _, rf := range pending {
+		if rf.skip {
+			continue
+		}
+
+		field := rf.Field
+		opts.recordOrigin(field, rf.source)
+		opts.notifyField(field, rf.value, rf.source)
+		if !rf.ok {
+			key := field.Path + "," + field.EnvVariable()
+			if err = requiredFailure(field, key, resolved, opts); err != nil {
+				failed = failure.Append(failed, newConfigError(field, ReasonMissing, err))
 			}
 			continue
 		}
 
-		if err = ProcessField(value, field.ReflectValue); err != nil {
-			return failure.Wrap(err, "ProcessField failed (%s)", field.Name)
+		if rf.preset.IsValid() {
+			setPreset(field.ReflectValue, rf.preset)
+			continue
+		}
+
+		if err = processValue(rf.value, rf.items, field, opts.lookupEnv()); err != nil {
+			err = failure.Wrap(err, "ProcessField failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonInvalid, err))
+			continue
+		}
+	}
+
+	if err = failed.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	if opts.only != nil {
+		return nil
+	}
+
+	return Validate(spec)
+}
+
+// ProcessEnvFields is ProcessEnv limited to the fields named in names, every
+// other field is left untouched, which suits a partial reload. A name is the
+// Go field name, the field path or the env var, with or without the prefix.
+// Validate is not run and a name that matches no field is a failure.
+func ProcessEnvFields(spec interface{}, names []string, prefix ...string) error {
+	return processEnvFields(spec, names, defaultOptions(), prefix...)
+}
+
+func processEnvFields(spec interface{}, names []string, opts options, prefix ...string) error {
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	matches := func(field Field) bool {
+		return wanted[field.Name] || wanted[field.Path] || wanted[field.EnvVariable()] || wanted[field.EnvVar]
+	}
+
+	found := map[string]bool{}
+	for _, field := range fields {
+		for _, key := range []string{field.Name, field.Path, field.EnvVariable(), field.EnvVar} {
+			found[key] = true
+		}
+	}
+
+	var failed *failure.Multi
+	for _, name := range names {
+		if !found[name] {
+			failed = failure.Append(failed, failure.NotFound("field (%s) is not in the spec", name))
 		}
 	}
+	if err = failed.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	opts.only = matches
+	return processEnv(spec, opts, prefix...)
+}
+
+// resolvedField is a field along with the value found for it, ok is false
+// when neither a source nor a default provided one. items is set when the
+// value comes from the numbered variables of an indexed field. preset is set
+// when the value comes from a defaults struct and is used as is. skip is set
+// for fields that are only resolved so others can refer to them.
+type resolvedField struct {
+	Field
+	value  string
+	items  []string
+	ok     bool
+	source ValueSource
+	preset reflect.Value
+	skip   bool
+}
+
+// requiredFailure is the failure for a field nothing had a value for, nil
+// when the field is not required. key names the field in the message.
+func requiredFailure(field Field, key string, resolved map[string]string, opts options) error {
+	if field.IsRequired() || opts.requireAll {
+		return failure.Config("required key (%s) missing value", key)
+	}
+
+	if cond, ok := requiredCondition(field, resolved, opts.environment); ok {
+		return failure.Config("required key (%s) missing value, %s", key, cond)
+	}
 
 	return nil
 }
 
+// requiredCondition checks the required-if and required-unless tags against
+// the resolved values of the other fields, which are keyed by env var, and
+// required-env against environment. It returns the condition that makes the
+// field required.
+func requiredCondition(field Field, resolved map[string]string, environment string) (string, bool) {
+	if field.IsRequiredIn(environment) {
+		return fmt.Sprintf("required-env (%s)", environment), true
+	}
+
+	if cond := field.Tag.RequiredIf; cond != "" && conditionMet(cond, resolved) {
+		return fmt.Sprintf("required-if (%s)", cond), true
+	}
+
+	if cond := field.Tag.RequiredUnless; cond != "" && !conditionMet(cond, resolved) {
+		return fmt.Sprintf("required-unless (%s)", cond), true
+	}
+
+	return "", false
+}
+
+// conditionMet evaluates a KEY=VALUE condition. Values that are both
+// booleans are compared as booleans so TLS_ENABLED=true matches on and 1.
+func conditionMet(cond string, resolved map[string]string) bool {
+	parts := strings.SplitN(cond, "=", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	actual, ok := resolved[parts[0]]
+	if !ok {
+		return false
+	}
+
+	expected := parts[1]
+	a, aErr := ParseBool(actual)
+	e, eErr := ParseBool(expected)
+	if aErr == nil && eErr == nil {
+		return a == e
+	}
+
+	return actual == expected
+}
+
 func PStoreKey(field Field, appTitle, env string) string {
 	var key string
 	pkey := field.ParamStoreKey()
@@ -329,18 +1194,21 @@ func PStoreKey(field Field, appTitle, env string) string {
 }
 
 func CollectParamsFromEnv(appTitle string, spec interface{}, skipDefaults bool, prefix ...string) (map[string]string, error) {
+	return collectParamsFromEnv(appTitle, spec, skipDefaults, defaultOptions(), prefix...)
+}
+
+func collectParamsFromEnv(appTitle string, spec interface{}, skipDefaults bool, opts options, prefix ...string) (map[string]string, error) {
 	if appTitle == "" {
 		return nil, failure.System("appTitle is empty")
 	}
 
-	fields, err := Fields(spec, prefix...)
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
 	result := map[string]string{}
 
-OUTER:
 	for _, field := range fields {
 		env := field.EnvVariable()
 		key := PStoreKey(field, appTitle, env)
@@ -350,16 +1218,18 @@ OUTER:
 		}
 
 		if env == "" {
-			return result, failure.System("env: is required but empty for (%s)", field.Name)
+			return result, failure.System("env: is required but empty for (%s)", field.Path)
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		if isExcluded(env, opts.excluded) {
+			continue
+		}
+
+		value, ok, err := lookupEnv(field)
+		if err != nil {
+			return result, failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
 		}
 
-		value, ok := os.LookupEnv(env)
 		if !ok {
 			if field.IsDefault() {
 				if skipDefaults {
@@ -367,7 +1237,7 @@ OUTER:
 				}
 				value = field.DefaultValue()
 			} else if field.IsRequired() {
-				return result, failure.Config("required key (%s,%s) missing value", field.Name, env)
+				return result, failure.Config("required key (%s,%s) missing value", field.Path, env)
 			}
 		}
 
@@ -386,7 +1256,7 @@ func ParamEnvField(appTitle, env string, field Field) (string, string, error) {
 
 	if !ok && !field.IsDefault() {
 		if field.IsRequired() {
-			return key, value, failure.Config("required key (%s,%s) missing value", field.Name, env)
+			return key, value, failure.Config("required key (%s,%s) missing value", field.Path, env)
 		}
 	}
 
@@ -394,18 +1264,21 @@ func ParamEnvField(appTitle, env string, field Field) (string, string, error) {
 }
 
 func ParamNames(appTitle string, spec interface{}, skipDefaults bool, prefix ...string) ([]string, error) {
+	return paramNames(appTitle, spec, skipDefaults, defaultOptions(), prefix...)
+}
+
+func paramNames(appTitle string, spec interface{}, skipDefaults bool, opts options, prefix ...string) ([]string, error) {
 	if appTitle == "" {
 		return nil, failure.System("appTitle is empty")
 	}
 
-	fields, err := Fields(spec, prefix...)
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
 	var result []string
 
-OUTER:
 	for _, field := range fields {
 		env := field.EnvVariable()
 		key := PStoreKey(field, appTitle, env)
@@ -415,13 +1288,11 @@ OUTER:
 		}
 
 		if env == "" {
-			return result, failure.System("env: is required but empty for (%s)", field.Name)
+			return result, failure.System("env: is required but empty for (%s)", field.Path)
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		if isExcluded(env, opts.excluded) {
+			continue
 		}
 
 		if skipDefaults && field.IsDefault() {
@@ -434,75 +1305,212 @@ OUTER:
 	return result, nil
 }
 
+// EnvReport returns the value of every env var spec reads, keyed by name,
+// falling back to the default for vars that are not set. Fields that
+// implement encoding.TextMarshaler and have already been populated are
+// reported in their MarshalText form.
 func EnvReport(spec interface{}, prefix ...string) (map[string]string, error) {
-	fields, err := Fields(spec, prefix...)
+	return envReport(spec, defaultOptions(), prefix...)
+}
+
+func envReport(spec interface{}, opts options, prefix ...string) (map[string]string, error) {
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
 	result := map[string]string{}
 
-OUTER:
 	for _, field := range fields {
 		env := field.EnvVariable()
-		if env == "-" {
+		if env == "-" || isExcluded(env, opts.excluded) {
 			continue
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		if env == "" {
+			return result, failure.System("env: is required but empty for (%s)", field.Path)
 		}
 
-		if env == "" {
-			return result, failure.System("env: is required but empty for (%s)", field.Name)
+		value, ok, err := lookupEnv(field)
+		if err != nil {
+			return result, failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
 		}
 
-		value, ok := os.LookupEnv(env)
 		if !ok && field.IsDefault() {
 			value = field.DefaultValue()
 		}
 
+		if value, err = reportValue(field, value); err != nil {
+			return result, failure.Wrap(err, "reportValue failed (%s)", field.Path)
+		}
+
 		result[env] = value
 	}
 
 	return result, nil
 }
 
+// reportValue is the MarshalText form of the current value of field when it
+// implements encoding.TextMarshaler and has been populated, so the report
+// shows the normalized value rather than what was typed. Otherwise it is
+// value unchanged.
+func reportValue(field Field, value string) (string, error) {
+	v := field.ReflectValue
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return value, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.IsZero() {
+		return value, nil
+	}
+
+	m := TextMarshaler(v)
+	if m == nil {
+		return value, nil
+	}
+
+	text, err := m.MarshalText()
+	if err != nil {
+		return "", failure.ToSystem(err, "MarshalText failed")
+	}
+
+	return string(text), nil
+}
+
+// EnvReportMasked is EnvReport made safe for logging. Values of fields tagged
+// mask are replaced with MaskedValue and fields tagged no-print are left out.
+func EnvReportMasked(spec interface{}, prefix ...string) (map[string]string, error) {
+	return envReportMasked(spec, defaultOptions(), prefix...)
+}
+
+func envReportMasked(spec interface{}, opts options, prefix ...string) (map[string]string, error) {
+	result, err := envReport(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "EnvReport failed")
+	}
+
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	redactValues(result, claimEnvVars(fields), false)
+
+	return result, nil
+}
+
+// ReportEntry is one field in the output of EnvReportJSON
+type ReportEntry struct {
+	Name     string `json:"name"`
+	EnvVar   string `json:"env"`
+	Value    string `json:"value"`
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+	Masked   bool   `json:"masked"`
+}
+
+// EnvReportJSON is EnvReportMasked as a JSON array with an entry per field,
+// in field order, that keeps the metadata a flat map loses. The value and
+// default of fields tagged mask are MaskedValue and fields tagged no-print
+// are left out.
+func EnvReportJSON(spec interface{}, prefix ...string) ([]byte, error) {
+	return envReportJSON(spec, defaultOptions(), prefix...)
+}
+
+func envReportJSON(spec interface{}, opts options, prefix ...string) ([]byte, error) {
+	values, err := envReportMasked(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "EnvReportMasked failed")
+	}
+
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	entries := make([]ReportEntry, 0, len(values))
+	for _, field := range fields {
+		env := field.EnvVariable()
+		value, ok := values[env]
+		if !ok {
+			continue
+		}
+
+		entry := ReportEntry{
+			Name:     field.Path,
+			EnvVar:   env,
+			Value:    value,
+			Required: field.IsRequired(),
+			Default:  field.DefaultValue(),
+			Masked:   field.IsMasked(),
+		}
+		if entry.Masked && entry.Default != "" {
+			entry.Default = MaskedValue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	out, err := json.Marshal(entries)
+	if err != nil {
+		return nil, failure.ToSystem(err, "json.Marshal failed")
+	}
+
+	return out, nil
+}
+
 func EnvToMap(spec interface{}, prefix ...string) (map[string]string, error) {
-	fields, err := Fields(spec, prefix...)
+	return envToMap(spec, defaultOptions(), prefix...)
+}
+
+// EnvToMapFiltered is EnvToMap limited to the fields pred accepts, fields
+// it rejects are neither read nor checked
+//
+//	conf.EnvToMapFiltered(&config, conf.Field.IsRequired)
+func EnvToMapFiltered(spec interface{}, pred func(Field) bool, prefix ...string) (map[string]string, error) {
+	opts := defaultOptions()
+	opts.only = pred
+
+	return envToMap(spec, opts, prefix...)
+}
+
+func envToMap(spec interface{}, opts options, prefix ...string) (map[string]string, error) {
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
 	result := map[string]string{}
 
-OUTER:
 	for _, field := range fields {
-		env := field.EnvVariable()
-		if env == "-" {
+		if opts.only != nil && !opts.only(field) {
 			continue
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		env := field.EnvVariable()
+		if env == "-" || isExcluded(env, opts.excluded) {
+			continue
 		}
 
 		if env == "" {
-			return result, failure.System("env: is required but empty for (%s)", field.Name)
+			return result, failure.System("env: is required but empty for (%s)", field.Path)
+		}
+
+		value, ok, err := lookupEnv(field)
+		if err != nil {
+			return result, failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
 		}
 
-		value, ok := os.LookupEnv(env)
 		if !ok && field.IsDefault() {
 			value = field.DefaultValue()
 		}
 
 		if !ok && !field.IsDefault() {
 			if field.IsRequired() {
-				return result, failure.Config("required key (%s,%s) missing value", field.Name, env)
+				return result, failure.Config("required key (%s,%s) missing value", field.Path, env)
 			}
 		}
 
@@ -520,17 +1528,14 @@ func EnvNamesNoDefaults(spec interface{}, prefix ...string) ([]string, error) {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
-OUTER:
 	for _, field := range fields {
 		env := field.EnvVariable()
 		if env == "-" || field.IsDefault() {
 			continue
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		if isExcluded(env, excludedVars) {
+			continue
 		}
 		names = append(names, env)
 	}
@@ -539,31 +1544,142 @@ OUTER:
 }
 
 func EnvNames(spec interface{}, prefix ...string) ([]string, error) {
+	return envNames(spec, defaultOptions(), false, prefix...)
+}
+
+// EnvNamesWithAliases is like EnvNames but each field's env-alias names
+// follow its primary name.
+func EnvNamesWithAliases(spec interface{}, prefix ...string) ([]string, error) {
+	return envNames(spec, defaultOptions(), true, prefix...)
+}
+
+// EnvName is the resolved env var of a field. Explicit is true when the
+// name came from the env tag and false when it was derived, see NameCase.
+type EnvName struct {
+	Name     string
+	Explicit bool
+}
+
+// EnvNameMap returns the resolved env var of every field in spec keyed by the
+// field path. Fields without an env var, with env:"-" or excluded are left out.
+func EnvNameMap(spec interface{}, prefix ...string) (map[string]EnvName, error) {
+	return envNameMap(spec, defaultOptions(), prefix...)
+}
+
+func envNameMap(spec interface{}, opts options, prefix ...string) (map[string]EnvName, error) {
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	result := map[string]EnvName{}
+	for _, field := range fields {
+		env := field.EnvVariable()
+		if field.Tag.EnvVar == "-" || env == "" || isExcluded(env, opts.excluded) {
+			continue
+		}
+
+		result[field.Path] = EnvName{Name: env, Explicit: !field.IsEnvDerived()}
+	}
+
+	return result, nil
+}
+
+func envNames(spec interface{}, opts options, includeAliases bool, prefix ...string) ([]string, error) {
 	var names []string
 
-	fields, err := Fields(spec, prefix...)
+	fields, err := specFields(spec, opts, prefix...)
 	if err != nil {
 		return nil, failure.Wrap(err, "Fields failed")
 	}
 
-OUTER:
 	for _, field := range fields {
 		env := field.EnvVariable()
 		if env == "-" {
 			continue
 		}
 
-		for _, ev := range excludedVars {
-			if env == ev {
-				continue OUTER
-			}
+		if isExcluded(env, opts.excluded) {
+			continue
 		}
 		names = append(names, env)
+
+		if includeAliases {
+			names = append(names, field.EnvAliases()...)
+		}
 	}
 
 	return names, nil
 }
 
+func isExcluded(env string, excluded []string) bool {
+	for _, ev := range excluded {
+		if env == ev {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookupEnv finds the value of the field's env variable. Fields tagged
+// from-file first check the companion <ENV>_FILE variable and, when it is set,
+// read the value from the file it points to instead. This is the convention
+// used for secrets mounted by docker and kubernetes. When the env variable is
+// not set its env-alias names are tried in order. The numbered variables of
+// an indexed field are joined with the slice delimiter, which is fine for
+// reports but not for processing, see lookupIndexed.
+func lookupEnv(field Field) (string, bool, error) {
+	value, ok, err := lookupVar(field, os.LookupEnv)
+	if ok || err != nil || !field.IsIndexed() {
+		return value, ok, err
+	}
+
+	items, ok := lookupIndexed(field, os.LookupEnv)
+	return strings.Join(items, field.SliceDelimiter()), ok, nil
+}
+
+// lookupVar is lookupEnv with the variables coming from lookup
+func lookupVar(field Field, lookup func(key string) (string, bool)) (string, bool, error) {
+	if field.IsFromFile() {
+		if path, ok := lookup(field.FileEnvVariable()); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", false, failure.ToConfig(err, "os.ReadFile failed (%s=%s)", field.FileEnvVariable(), path)
+			}
+			return strings.TrimSpace(string(data)), true, nil
+		}
+	}
+
+	if value, ok := lookup(field.EnvVariable()); ok {
+		return value, true, nil
+	}
+
+	for _, alias := range field.EnvAliases() {
+		if value, ok := lookup(alias); ok {
+			return value, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// lookupIndexed reads the numbered variables of an indexed field, from
+// <ENV>_0 up to the first gap. The items are kept apart so they may contain
+// the slice delimiter, see processItems.
+func lookupIndexed(field Field, lookup func(key string) (string, bool)) ([]string, bool) {
+	var items []string
+	for i := 0; ; i++ {
+		value, ok := lookup(field.IndexedEnvVariable(i))
+		if !ok {
+			break
+		}
+		items = append(items, value)
+	}
+
+	return items, len(items) > 0
+}
+
 // EnvVar ensures the variable you are looking for is set. If you don't care
 // about that use EnvVarOptional instead
 func EnvVar(key string) (string, error) {
@@ -597,3 +1713,47 @@ func EnvVarStrict(key string) (string, error) {
 func EnvVarOptional(key string) string {
 	return os.Getenv(key)
 }
+
+// EnvVarDefault returns the env var or fallback when it is unset or empty,
+// an empty value is treated like a missing one the same way EnvVarStrict does
+func EnvVarDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// EnvVarAs looks up the env var like EnvVar and converts it to T with the
+// same rules ProcessField uses for struct fields. On failure the zero value
+// of T is returned.
+func EnvVarAs[T any](key string) (T, error) {
+	var result T
+	value, err := EnvVar(key)
+	if err != nil {
+		return result, failure.Wrap(err, "EnvVar failed")
+	}
+
+	if err = ProcessField(value, reflect.ValueOf(&result).Elem()); err != nil {
+		var zero T
+		return zero, failure.Wrap(err, "ProcessField failed (%s)", key)
+	}
+
+	return result, nil
+}
+
+func EnvVarInt(key string) (int, error) {
+	return EnvVarAs[int](key)
+}
+
+func EnvVarBool(key string) (bool, error) {
+	return EnvVarAs[bool](key)
+}
+
+func EnvVarDuration(key string) (time.Duration, error) {
+	return EnvVarAs[time.Duration](key)
+}
+
+func EnvVarFloat(key string) (float64, error) {
+	return EnvVarAs[float64](key)
+}
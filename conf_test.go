@@ -1,10 +1,19 @@
 package conf_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rsb/conf"
+	"github.com/rsb/failure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -82,6 +91,29 @@ func TestProcessEnv_ProcessFieldFailure(t *testing.T) {
 	assert.Contains(t, err.Error(), "ProcessField failed (Nbr)")
 }
 
+func TestProcessEnv_CollectsAllFailures(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:MY_HOST,required"`
+		Name string `conf:"env:MY_NAME,required"`
+		Nbr  int    `conf:"env:MY_NBR"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_NBR", "abc")
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.Error(t, err, "conf.ProcessEnv is expected to fail")
+
+	failures, ok := failure.MultiResult(err)
+	require.True(t, ok, "expected a failure.Multi")
+	assert.Len(t, failures, 3)
+	assert.Contains(t, err.Error(), "required key (Host,MY_HOST) missing value")
+	assert.Contains(t, err.Error(), "required key (Name,MY_NAME) missing value")
+	assert.Contains(t, err.Error(), "ProcessField failed (Nbr)")
+	os.Clearenv()
+}
+
 func TestProcessEnvNoPrefix_Success(t *testing.T) {
 	os.Clearenv()
 
@@ -109,6 +141,98 @@ func TestProcessEnvNoPrefix_Success(t *testing.T) {
 	assert.Equal(t, "/curriculum.log", config.DB.Logfile)
 }
 
+func TestProcessEnv_SliceDelimiter(t *testing.T) {
+	type MyConfig struct {
+		Paths []string `conf:"env:MY_PATHS,delim:;"`
+		Ports []int    `conf:"env:MY_PORTS"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_PATHS", "/a/b,c;/d,e")
+	setenv(t, "MY_PORTS", "80,443")
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, []string{"/a/b,c", "/d,e"}, config.Paths)
+	assert.Equal(t, []int{80, 443}, config.Ports)
+	os.Clearenv()
+}
+
+func TestProcessEnv_MapSeparators(t *testing.T) {
+	type MyConfig struct {
+		Servers map[string]string `conf:"env:MY_SERVERS,map-kv-sep:=,map-pair-sep:;"`
+		Codes   map[string]string `conf:"env:MY_CODES"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_SERVERS", "a=http://x;b=http://y")
+	setenv(t, "MY_CODES", "codeA:A,codeB:B")
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, map[string]string{"a": "http://x", "b": "http://y"}, config.Servers)
+	assert.Equal(t, map[string]string{"codeA": "A", "codeB": "B"}, config.Codes)
+	os.Clearenv()
+}
+
+func TestProcessEnv_MapSeparatorsInvalidItem(t *testing.T) {
+	type MyConfig struct {
+		Servers map[string]string `conf:"env:MY_SERVERS,map-kv-sep:=,map-pair-sep:;"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_SERVERS", "a=http://x=y;b=http://y")
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.Error(t, err, "conf.ProcessEnv is expected to fail")
+	assert.Contains(t, err.Error(), "invalid map item")
+	os.Clearenv()
+}
+
+func TestProcessEnv_FromFile(t *testing.T) {
+	type MyConfig struct {
+		Pass string `conf:"env:DB_PASS,required,from-file"`
+		User string `conf:"env:DB_USER,from-file"`
+		Name string `conf:"env:DB_NAME"`
+	}
+
+	path := filepath.Join(t.TempDir(), "db_pass")
+	require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0600))
+
+	os.Clearenv()
+	setenv(t, "DB_PASS", "direct-pass")
+	setenv(t, "DB_PASS_FILE", path)
+	setenv(t, "DB_USER", "direct-user")
+	setenv(t, "DB_NAME_FILE", path)
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, "s3cret", config.Pass)
+	assert.Equal(t, "direct-user", config.User)
+	assert.Equal(t, "", config.Name)
+	os.Clearenv()
+}
+
+func TestProcessEnv_FromFileMissing(t *testing.T) {
+	type MyConfig struct {
+		Pass string `conf:"env:DB_PASS,required,from-file"`
+	}
+
+	os.Clearenv()
+	setenv(t, "DB_PASS_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.Error(t, err, "conf.ProcessEnv is expected to fail")
+	assert.Contains(t, err.Error(), "lookupEnv failed (Pass)")
+	assert.Contains(t, err.Error(), "os.ReadFile failed (DB_PASS_FILE=")
+	os.Clearenv()
+}
+
 func TestEnvVar_Success(t *testing.T) {
 	os.Clearenv()
 	setenv(t, "FOO", "Bar")
@@ -174,6 +298,58 @@ func TestEnvVarOptional(t *testing.T) {
 
 }
 
+func TestEnvVarDefault(t *testing.T) {
+	os.Clearenv()
+	assert.Equal(t, "fallback", conf.EnvVarDefault("FOO", "fallback"))
+
+	setenv(t, "FOO", "")
+	assert.Equal(t, "fallback", conf.EnvVarDefault("FOO", "fallback"))
+
+	setenv(t, "FOO", "Bar")
+	assert.Equal(t, "Bar", conf.EnvVarDefault("FOO", "fallback"))
+	os.Clearenv()
+}
+
+func TestEnvVarAs(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "PORT", "8080")
+	setenv(t, "DEBUG", "yes")
+	setenv(t, "TIMEOUT", "30s")
+	setenv(t, "RATIO", "0.5")
+	setenv(t, "HOSTS", "a,b")
+	setenv(t, "BAD", "abc")
+
+	port, err := conf.EnvVarInt("PORT")
+	require.NoError(t, err, "conf.EnvVarInt is not expected to fail")
+	assert.Equal(t, 8080, port)
+
+	debug, err := conf.EnvVarBool("DEBUG")
+	require.NoError(t, err, "conf.EnvVarBool is not expected to fail")
+	assert.True(t, debug)
+
+	timeout, err := conf.EnvVarDuration("TIMEOUT")
+	require.NoError(t, err, "conf.EnvVarDuration is not expected to fail")
+	assert.Equal(t, 30*time.Second, timeout)
+
+	ratio, err := conf.EnvVarFloat("RATIO")
+	require.NoError(t, err, "conf.EnvVarFloat is not expected to fail")
+	assert.Equal(t, 0.5, ratio)
+
+	hosts, err := conf.EnvVarAs[[]string]("HOSTS")
+	require.NoError(t, err, "conf.EnvVarAs is not expected to fail")
+	assert.Equal(t, []string{"a", "b"}, hosts)
+
+	bad, err := conf.EnvVarInt("BAD")
+	require.Error(t, err, "conf.EnvVarInt is expected to fail")
+	assert.Contains(t, err.Error(), "ProcessField failed (BAD)")
+	assert.Equal(t, 0, bad)
+
+	_, err = conf.EnvVarInt("MISSING")
+	require.Error(t, err, "conf.EnvVarInt is expected to fail")
+	assert.Contains(t, err.Error(), "env var (MISSING) is not set")
+	os.Clearenv()
+}
+
 func setenv(t *testing.T, key, value string) {
 	require.NoError(t, os.Setenv(key, value))
 }
@@ -272,6 +448,356 @@ func TestBindCLI_PersistentFlagsSuccess(t *testing.T) {
 	assert.Equal(t, "true", result.DefValue)
 }
 
+func TestBindCLI_FlagScope(t *testing.T) {
+	var cmd = &cobra.Command{
+		Use: "my-cmd",
+	}
+
+	type MyConfig struct {
+		Persistent string `conf:"cli:persistent,flag-scope:persistent"`
+		Local      string `conf:"cli:local,flag-scope:local"`
+		Unscoped   string `conf:"cli:unscoped"`
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err)
+
+	assert.NotNil(t, cmd.PersistentFlags().Lookup("persistent"))
+	assert.Nil(t, cmd.LocalNonPersistentFlags().Lookup("persistent"))
+
+	assert.NotNil(t, cmd.LocalNonPersistentFlags().Lookup("local"))
+	assert.Nil(t, cmd.PersistentFlags().Lookup("local"))
+
+	assert.NotNil(t, cmd.LocalNonPersistentFlags().Lookup("unscoped"))
+	assert.Nil(t, cmd.PersistentFlags().Lookup("unscoped"))
+}
+
+func TestBindCLI_TypedFlags(t *testing.T) {
+	var cmd = &cobra.Command{
+		Use: "my-cmd",
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+
+	type MyConfig struct {
+		Port    int           `conf:"cli:port,default:8080"`
+		Workers uint8         `conf:"cli:workers"`
+		Ratio   float64       `conf:"cli:ratio,default:0.5"`
+		Timeout time.Duration `conf:"cli:timeout,default:30s"`
+		Limit   int64         `conf:"cli:limit,size,default:1MB"`
+		Mode    uint32        `conf:"cli:mode,base:8,default:644"`
+		Name    string        `conf:"cli:name"`
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err)
+
+	types := map[string]string{
+		"port":    "int",
+		"workers": "uint8",
+		"ratio":   "float64",
+		"timeout": "duration",
+		"limit":   "string",
+		"mode":    "string",
+		"name":    "string",
+	}
+	for name, typ := range types {
+		f := cmd.Flags().Lookup(name)
+		require.NotNil(t, f, name)
+		assert.Equal(t, typ, f.Value.Type(), name)
+	}
+
+	assert.Equal(t, "8080", cmd.Flags().Lookup("port").DefValue)
+	assert.Equal(t, "30s", cmd.Flags().Lookup("timeout").DefValue)
+
+	cmd.SetArgs([]string{"--port", "abc"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	err = cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid argument "abc" for "--port"`)
+}
+
+func TestBindCLI_TypedFlagDefaultFailure(t *testing.T) {
+	var cmd = &cobra.Command{
+		Use: "my-cmd",
+	}
+
+	type MyConfig struct {
+		Port int `conf:"cli:port,default:abc"`
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "strconv.ParseInt failed")
+}
+
+func TestBindCLI_ScopeCmds(t *testing.T) {
+	type MyConfig struct {
+		Port    int    `conf:"env:PORT,cli:port,scope-cmds:serve,default:8080"`
+		DryRun  bool   `conf:"cli:dry-run,scope-cmds:migrate|seed"`
+		Verbose bool   `conf:"cli:verbose"`
+		Target  string `conf:"cli:target,scope-cmds:deploy"`
+	}
+
+	root := &cobra.Command{Use: "app"}
+	serve := &cobra.Command{Use: "serve", Run: func(cmd *cobra.Command, args []string) {}}
+	migrate := &cobra.Command{Use: "migrate", Run: func(cmd *cobra.Command, args []string) {}}
+	root.AddCommand(serve, migrate)
+
+	var config MyConfig
+	require.NoError(t, conf.BindCLI(serve, viper.New(), &config))
+	require.NoError(t, conf.BindCLI(migrate, viper.New(), &config))
+
+	assert.NotNil(t, serve.Flags().Lookup("port"))
+	assert.NotNil(t, serve.Flags().Lookup("verbose"))
+	assert.Nil(t, serve.Flags().Lookup("dry-run"))
+	assert.Nil(t, migrate.Flags().Lookup("port"))
+	assert.NotNil(t, migrate.Flags().Lookup("dry-run"))
+	assert.NotNil(t, migrate.Flags().Lookup("verbose"))
+
+	other := &cobra.Command{Use: "release"}
+	require.NoError(t, conf.BindCLIScope(other, viper.New(), "deploy", &config))
+	assert.NotNil(t, other.Flags().Lookup("target"))
+	assert.Nil(t, other.Flags().Lookup("port"))
+
+	os.Clearenv()
+	setenv(t, "PORT", "9090")
+	v := viper.New()
+	migrate = &cobra.Command{Use: "migrate", Run: func(cmd *cobra.Command, args []string) {}}
+	require.NoError(t, conf.BindCLI(migrate, v, &config))
+	migrate.SetArgs([]string{"--dry-run"})
+	require.NoError(t, migrate.Execute())
+	require.NoError(t, conf.ProcessCLI(migrate, v, &config))
+	assert.Equal(t, 9090, config.Port)
+	assert.True(t, config.DryRun)
+	os.Clearenv()
+}
+
+func TestProcessCLI_ScopeCmdsRequired(t *testing.T) {
+	type MyConfig struct {
+		Addr   string `conf:"cli:addr,scope-cmds:serve,required"`
+		DryRun bool   `conf:"cli:dry-run,scope-cmds:migrate"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	v := viper.New()
+	migrate := &cobra.Command{Use: "migrate", Run: func(cmd *cobra.Command, args []string) {}}
+	require.NoError(t, conf.BindCLI(migrate, v, &config))
+	migrate.SetArgs([]string{"--dry-run"})
+	require.NoError(t, migrate.Execute())
+	require.NoError(t, conf.ProcessCLI(migrate, v, &config), "a flag not on migrate is not required by it")
+	assert.True(t, config.DryRun)
+
+	v = viper.New()
+	serve := &cobra.Command{Use: "serve", Run: func(cmd *cobra.Command, args []string) {}}
+	require.NoError(t, conf.BindCLI(serve, v, &config))
+	err := conf.ProcessCLI(serve, v, &config)
+	require.Error(t, err, "conf.ProcessCLI is expected to fail")
+	assert.Contains(t, err.Error(), "required key (field:Addr,env:,cli:addr) missing value")
+
+	type RootConfig struct {
+		Token string `conf:"cli:token,scope-cmds:app,global-flag,required"`
+	}
+
+	var rootConfig RootConfig
+	v = viper.New()
+	root := &cobra.Command{Use: "app"}
+	serve = &cobra.Command{Use: "serve", Run: func(cmd *cobra.Command, args []string) {}}
+	root.AddCommand(serve)
+	require.NoError(t, conf.BindCLI(root, v, &rootConfig))
+	err = conf.ProcessCLI(serve, v, &rootConfig)
+	require.Error(t, err, "a flag serve inherits from its parent is still required")
+	assert.Contains(t, err.Error(), "required key (field:Token,env:,cli:token) missing value")
+}
+
+func TestBindCLI_DocUsage(t *testing.T) {
+	type MyConfig struct {
+		Workers int    `conf:"cli:workers,cli-u:worker count,doc:tune based on CPU count"`
+		Region  string `conf:"cli:region,doc:AWS region, like us-east-1"`
+		Name    string `conf:"cli:name,cli-u:the name"`
+	}
+
+	cmd := &cobra.Command{Use: "my-cmd"}
+	require.NoError(t, conf.BindCLI(cmd, viper.New(), &MyConfig{}))
+	assert.Equal(t, "worker count", cmd.Flags().Lookup("workers").Usage)
+	assert.Equal(t, "AWS region, like us-east-1", cmd.Flags().Lookup("region").Usage)
+	assert.Equal(t, "the name", cmd.Flags().Lookup("name").Usage)
+
+	conf.DocInUsage = true
+	defer func() { conf.DocInUsage = false }()
+
+	cmd = &cobra.Command{Use: "my-cmd"}
+	require.NoError(t, conf.BindCLI(cmd, viper.New(), &MyConfig{}))
+	assert.Equal(t, "worker count. tune based on CPU count", cmd.Flags().Lookup("workers").Usage)
+	assert.Equal(t, "the name", cmd.Flags().Lookup("name").Usage)
+}
+
+func TestBindCLI_HiddenFlags(t *testing.T) {
+	var cmd = &cobra.Command{
+		Use: "my-cmd",
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+
+	type MyConfig struct {
+		Old     string `conf:"cli:old-name,cli-u:deprecated,hidden"`
+		OldP    string `conf:"cli:old-global,cli-u:deprecated,hidden,global-flag"`
+		Current string `conf:"cli:name,cli-u:the name"`
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err)
+
+	assert.True(t, cmd.Flags().Lookup("old-name").Hidden)
+	assert.True(t, cmd.PersistentFlags().Lookup("old-global").Hidden)
+	assert.False(t, cmd.Flags().Lookup("name").Hidden)
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Usage())
+	assert.NotContains(t, out.String(), "old-name")
+	assert.NotContains(t, out.String(), "old-global")
+	assert.Contains(t, out.String(), "--name")
+
+	cmd.SetArgs([]string{"--old-name", "x", "--old-global", "y"})
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, "x", cmd.Flags().Lookup("old-name").Value.String())
+}
+
+func TestBindCLI_DeprecatedFlags(t *testing.T) {
+	var cmd = &cobra.Command{
+		Use: "my-cmd",
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+
+	type MyConfig struct {
+		Old  string `conf:"cli:old-name,deprecated:use --new-name instead"`
+		OldP string `conf:"cli:old-global,deprecated:use --new-global instead,global-flag"`
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err)
+
+	assert.Equal(t, "use --new-name instead", cmd.Flags().Lookup("old-name").Deprecated)
+	assert.Equal(t, "use --new-global instead", cmd.PersistentFlags().Lookup("old-global").Deprecated)
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"--old-name", "x"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "Flag --old-name has been deprecated, use --new-name instead")
+	assert.Equal(t, "x", cmd.Flags().Lookup("old-name").Value.String())
+}
+
+func TestBindCLI_MutexGroups(t *testing.T) {
+	type MyConfig struct {
+		JSON    bool   `conf:"cli:json,mutex-group:output"`
+		YAML    bool   `conf:"cli:yaml,mutex-group:output"`
+		Verbose bool   `conf:"cli:verbose"`
+		Name    string `conf:"cli:name"`
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		msg  string
+	}{
+		{name: "one of the group", args: []string{"--json", "--verbose"}},
+		{name: "none of the group", args: []string{"--name", "x"}},
+		{
+			name: "both of the group",
+			args: []string{"--json", "--yaml"},
+			msg:  "if any flags in the group [json yaml] are set none of the others can be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{
+				Use: "my-cmd",
+				Run: func(cmd *cobra.Command, args []string) {},
+			}
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			var config MyConfig
+			err := conf.BindCLI(cmd, viper.New(), &config)
+			require.NoError(t, err)
+
+			cmd.SetArgs(tt.args)
+			err = cmd.Execute()
+			if tt.msg == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestBindCLI_RequiredFlags(t *testing.T) {
+	type MyConfig struct {
+		Source string `conf:"cli:source,required"`
+		Region string `conf:"cli:region,required,global-flag"`
+		Host   string `conf:"env:MY_HOST,cli:host,required"`
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		msg  string
+	}{
+		{name: "all set", args: []string{"--source", "a", "--region", "b"}},
+		{
+			name: "local missing",
+			args: []string{"--region", "b"},
+			msg:  `required flag(s) "source" not set`,
+		},
+		{
+			name: "persistent missing",
+			args: []string{"--source", "a"},
+			msg:  `required flag(s) "region" not set`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			cmd := &cobra.Command{
+				Use: "my-cmd",
+				Run: func(cmd *cobra.Command, args []string) { ran = true },
+			}
+			cmd.SetOut(io.Discard)
+			cmd.SetErr(io.Discard)
+
+			var config MyConfig
+			err := conf.BindCLI(cmd, viper.New(), &config)
+			require.NoError(t, err)
+
+			_, ok := cmd.Flags().Lookup("host").Annotations[cobra.BashCompOneRequiredFlag]
+			assert.False(t, ok, "env backed fields are not required by cobra")
+
+			cmd.SetArgs(tt.args)
+			err = cmd.Execute()
+			if tt.msg == "" {
+				require.NoError(t, err)
+				assert.True(t, ran)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.msg)
+			assert.False(t, ran)
+		})
+	}
+}
+
 func TestBindCLI_WithBoolFieldNoShort_Success(t *testing.T) {
 	var cmd = &cobra.Command{
 		Use: "my-cmd",
@@ -347,7 +873,7 @@ func TestBindCLI_SkipCliSuccess(t *testing.T) {
 
 func TestProcessCLI_SimpleFieldSuccess(t *testing.T) {
 	type MyConfig struct {
-		Field string `conf:"env:MY_FIELD,default:abc,cmds:my-field,cmds-s:f,cmds-u:some field usage"`
+		Field string `conf:"env:MY_FIELD,default:abc,cli:my-field,cli-s:f,cli-u:some field usage"`
 	}
 
 	expectedValue := "foobar"
@@ -374,6 +900,65 @@ func TestProcessCLI_SimpleFieldSuccess(t *testing.T) {
 
 	cmd.SetArgs([]string{"--my-field", expectedValue})
 	err = cmd.Execute()
+	require.NoError(t, err, "cmd.Execute is not expected to fail")
+}
+
+func TestProcessCLI_CmdsAliases(t *testing.T) {
+	type MyConfig struct {
+		Field string `conf:"env:MY_FIELD,default:abc,cmds:my-field,cmds-s:f,cmds-u:some field usage"`
+	}
+
+	cmd := &cobra.Command{
+		Use: "my-cmd",
+	}
+
+	v := viper.New()
+	var config MyConfig
+	err := conf.BindCLI(cmd, v, &config)
+	require.NoError(t, err, "conf.BindCLI is not expected to fail")
+
+	flag := cmd.Flags().Lookup("my-field")
+	require.NotNil(t, flag, "cmds binds the flag like cli")
+	assert.Equal(t, "f", flag.Shorthand)
+	assert.Equal(t, "some field usage", flag.Usage)
+
+	require.NoError(t, cmd.ParseFlags([]string{"-f", "foobar"}))
+	err = conf.ProcessCLI(cmd, v, &config)
+	require.NoError(t, err, "conf.ProcessCLI is not expected to fail")
+	assert.Equal(t, "foobar", config.Field)
+}
+
+func TestProcessCLI_SliceFlags(t *testing.T) {
+	type MyConfig struct {
+		Tags  []string `conf:"cli:tag,default:list(x;y)"`
+		Ports []int    `conf:"cli:port,delim:|"`
+	}
+
+	cmd := &cobra.Command{
+		Use: "my-cmd",
+	}
+
+	v := viper.New()
+	var config MyConfig
+	err := conf.BindCLI(cmd, v, &config)
+	require.NoError(t, err)
+
+	tags := cmd.Flags().Lookup("tag")
+	require.NotNil(t, tags)
+	assert.Equal(t, "stringSlice", tags.Value.Type())
+	assert.Equal(t, "[x,y]", tags.DefValue)
+	assert.Equal(t, "intSlice", cmd.Flags().Lookup("port").Value.Type())
+
+	var result MyConfig
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return conf.ProcessCLI(cmd, v, &result)
+	}
+
+	cmd.SetArgs([]string{"--tag", "a", "--tag", "b,c", "--port", "80", "--port", "443"})
+	err = cmd.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, result.Tags)
+	assert.Equal(t, []int{80, 443}, result.Ports)
 }
 
 func TestProcessCLI_SimpleFieldDefaultValue(t *testing.T) {
@@ -764,3 +1349,865 @@ func TestEnvToMap_RequiredFailure(t *testing.T) {
 
 	assert.Contains(t, err.Error(), "required key (FieldB,FIELD_B) missing value")
 }
+
+func TestEnvToMapFiltered(t *testing.T) {
+	type MyConfig struct {
+		FieldA string `conf:"env:FIELD_A,default:abc"`
+		FieldB string `conf:"env:FIELD_B,required"`
+		FieldC string `conf:"env:FIELD_C,mask"`
+		FieldD int    `conf:"env:FIELD_D,default:888"`
+	}
+
+	os.Clearenv()
+	setenv(t, "FIELD_B", "bbb")
+	setenv(t, "FIELD_C", "secret")
+
+	var config MyConfig
+	result, err := conf.EnvToMapFiltered(&config, conf.Field.IsRequired)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"FIELD_B": "bbb"}, result)
+
+	result, err = conf.EnvToMapFiltered(&config, func(f conf.Field) bool { return f.IsMasked() })
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"FIELD_C": "secret"}, result)
+
+	result, err = conf.EnvToMapFiltered(&config, conf.Field.IsDefault)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"FIELD_A": "abc", "FIELD_D": "888"}, result)
+}
+
+func TestEnvToMapFiltered_SkipsRequiredCheck(t *testing.T) {
+	type MyConfig struct {
+		FieldA string `conf:"env:FIELD_A,default:abc"`
+		FieldB string `conf:"env:FIELD_B,required"`
+	}
+
+	os.Clearenv()
+
+	var config MyConfig
+	result, err := conf.EnvToMapFiltered(&config, conf.Field.IsDefault)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"FIELD_A": "abc"}, result)
+
+	c := conf.Config{Data: &config}
+	_, err = c.EnvToMapFiltered(conf.Field.IsRequired)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key (FieldB,FIELD_B) missing value")
+}
+
+func TestEnvReport_TextMarshaler(t *testing.T) {
+	type MyConfig struct {
+		Start   time.Time  `conf:"env:START"`
+		End     *time.Time `conf:"env:END"`
+		Created time.Time  `conf:"env:CREATED,default:2024-01-02T03:04:05+00:00"`
+		Name    string     `conf:"env:NAME"`
+	}
+
+	os.Clearenv()
+	setenv(t, "START", "2024-06-01T12:00:00+00:00")
+	setenv(t, "NAME", " raw ")
+
+	var config MyConfig
+	report, err := conf.EnvReport(&config)
+	require.NoError(t, err, "conf.EnvReport is not expected to fail")
+	assert.Equal(t, "2024-06-01T12:00:00+00:00", report["START"], "unpopulated fields report the raw value")
+
+	require.NoError(t, conf.ProcessEnv(&config))
+	report, err = conf.EnvReport(&config)
+	require.NoError(t, err, "conf.EnvReport is not expected to fail")
+
+	expected := map[string]string{
+		"START":   "2024-06-01T12:00:00Z",
+		"END":     "",
+		"CREATED": "2024-01-02T03:04:05Z",
+		"NAME":    " raw ",
+	}
+	assert.Equal(t, expected, report)
+	os.Clearenv()
+}
+
+func TestEnvReportMasked(t *testing.T) {
+	type MyConfig struct {
+		Host   string `conf:"env:DB_HOST"`
+		Pass   string `conf:"env:DB_PASS,mask"`
+		Token  string `conf:"env:API_TOKEN,no-print"`
+		Secret string `conf:"env:SECRET,mask,default:abc"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_DB_HOST", "localhost")
+	setenv(t, "APP_DB_PASS", "s3cret")
+	setenv(t, "APP_API_TOKEN", "token")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+
+	raw, err := c.EnvReport()
+	require.NoError(t, err, "c.EnvReport is not expected to fail")
+	assert.Equal(t, "s3cret", raw["APP_DB_PASS"])
+
+	result, err := c.EnvReportMasked()
+	require.NoError(t, err, "c.EnvReportMasked is not expected to fail")
+
+	expected := map[string]string{
+		"APP_DB_HOST": "localhost",
+		"APP_DB_PASS": conf.MaskedValue,
+		"APP_SECRET":  conf.MaskedValue,
+	}
+	assert.Equal(t, expected, result)
+	os.Clearenv()
+}
+
+func TestEnvReportJSON(t *testing.T) {
+	type MyConfig struct {
+		Host   string `conf:"env:DB_HOST,required"`
+		Port   int    `conf:"env:DB_PORT,default:5432"`
+		Pass   string `conf:"env:DB_PASS,mask"`
+		Token  string `conf:"env:API_TOKEN,no-print"`
+		Secret string `conf:"env:SECRET,mask,default:abc"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_DB_HOST", "localhost")
+	setenv(t, "APP_DB_PASS", "s3cret")
+	setenv(t, "APP_API_TOKEN", "token")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	out, err := c.EnvReportJSON()
+	require.NoError(t, err, "c.EnvReportJSON is not expected to fail")
+
+	expected := `[
+		{"name":"Host","env":"APP_DB_HOST","value":"localhost","required":true,"masked":false},
+		{"name":"Port","env":"APP_DB_PORT","value":"5432","required":false,"default":"5432","masked":false},
+		{"name":"Pass","env":"APP_DB_PASS","value":"******","required":false,"masked":true},
+		{"name":"Secret","env":"APP_SECRET","value":"******","required":false,"default":"******","masked":true}
+	]`
+	assert.JSONEq(t, strings.ReplaceAll(expected, "******", conf.MaskedValue), string(out))
+	assert.NotContains(t, string(out), "s3cret")
+	assert.NotContains(t, string(out), "abc")
+
+	out, err = conf.EnvReportJSON(&config, "APP")
+	require.NoError(t, err, "conf.EnvReportJSON is not expected to fail")
+	assert.NotContains(t, string(out), "token")
+	os.Clearenv()
+}
+
+func TestConfig_String(t *testing.T) {
+	type MyConfig struct {
+		Host    string  `conf:"env:DB_HOST"`
+		Port    int     `conf:"env:DB_PORT,default:5432"`
+		Pass    string  `conf:"env:DB_PASS,mask"`
+		Secret  string  `conf:"env:SECRET,mask,default:abc"`
+		Token   string  `conf:"env:API_TOKEN,no-print"`
+		Timeout *string `conf:"env:TIMEOUT"`
+	}
+
+	os.Clearenv()
+	setenv(t, "DB_HOST", "localhost")
+	setenv(t, "DB_PASS", "s3cret")
+	setenv(t, "API_TOKEN", "token")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv())
+
+	expected := "Host=localhost\nPort=5432\nPass=****\nSecret=****\nTimeout=\n"
+	assert.Equal(t, expected, c.String())
+	assert.Equal(t, expected, fmt.Sprintf("%s", c))
+	assert.NotContains(t, c.String(), "s3cret")
+	assert.NotContains(t, c.String(), "token")
+	os.Clearenv()
+}
+
+func TestConfig_StringNestedRedaction(t *testing.T) {
+	type Credentials struct {
+		User     string `conf:"env:USER"`
+		Password string `conf:"env:PASSWORD"`
+	}
+	type Keys struct {
+		Private string `conf:"env:PRIVATE"`
+	}
+	type MyConfig struct {
+		Host  string      `conf:"env:HOST"`
+		Creds Credentials `conf:"prefix:DB,mask"`
+		Keys  *Keys       `conf:"prefix:KEYS,no-print"`
+		Plain Credentials `conf:"prefix:PLAIN"`
+	}
+
+	os.Clearenv()
+	setenv(t, "HOST", "localhost")
+	setenv(t, "DB_USER", "admin")
+	setenv(t, "DB_PASSWORD", "s3cret")
+	setenv(t, "KEYS_PRIVATE", "pem")
+	setenv(t, "PLAIN_USER", "guest")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv())
+
+	expected := "Host=localhost\nUser=****\nPassword=****\nUser=guest\nPassword=\n"
+	assert.Equal(t, expected, c.String())
+
+	report, err := conf.EnvReportMasked(&config)
+	require.NoError(t, err)
+	assert.Equal(t, conf.MaskedValue, report["DB_USER"])
+	assert.Equal(t, conf.MaskedValue, report["DB_PASSWORD"])
+	assert.Equal(t, "guest", report["PLAIN_USER"])
+	assert.NotContains(t, report, "KEYS_PRIVATE")
+	os.Clearenv()
+}
+
+func TestProcessCLI_PointerWithoutValueStaysNil(t *testing.T) {
+	type MyConfig struct {
+		Field   *string `conf:"env:MY_FIELD,cli:my-field"`
+		Default *string `conf:"env:MY_DEFAULT,cli:my-default,default:abc"`
+	}
+
+	os.Clearenv()
+	cmd := &cobra.Command{
+		Use: "my-cmd",
+	}
+	cmd.RunE = func(_ *cobra.Command, args []string) error {
+		var config MyConfig
+
+		err := conf.ProcessCLI(cmd, viper.New(), &config)
+		require.NoError(t, err, "conf.ProcessCLI is not expected to fail")
+		assert.Nil(t, config.Field)
+		require.NotNil(t, config.Default)
+		assert.Equal(t, "abc", *config.Default)
+		return nil
+	}
+
+	var config MyConfig
+	err := conf.BindCLI(cmd, viper.New(), &config)
+	require.NoError(t, err, "conf.BindCLI is not expected to fail")
+
+	cmd.SetArgs([]string{})
+	err = cmd.Execute()
+	require.NoError(t, err, "cmd.Execute is not expected to fail")
+}
+
+func TestConfig_IncludeExcludedVars(t *testing.T) {
+	type MyConfig struct {
+		AppName string `conf:"env:APP_NAME"`
+		Region  string `conf:"env:AWS_REGION"`
+		Host    string `conf:"env:DB_HOST"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_NAME", "my-app")
+	setenv(t, "AWS_REGION", "us-east-1")
+	setenv(t, "DB_HOST", "localhost")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+
+	result, err := c.EnvToMap()
+	require.NoError(t, err, "c.EnvToMap is not expected to fail")
+	assert.Equal(t, map[string]string{"DB_HOST": "localhost"}, result)
+
+	c.IncludeExcludedVars = true
+	expected := map[string]string{
+		"APP_NAME":   "my-app",
+		"AWS_REGION": "us-east-1",
+		"DB_HOST":    "localhost",
+	}
+
+	result, err = c.EnvToMap()
+	require.NoError(t, err, "c.EnvToMap is not expected to fail")
+	assert.Equal(t, expected, result)
+
+	result, err = c.EnvReport()
+	require.NoError(t, err, "c.EnvReport is not expected to fail")
+	assert.Equal(t, expected, result)
+
+	result, err = conf.EnvToMap(&config)
+	require.NoError(t, err, "conf.EnvToMap is not expected to fail")
+	assert.Equal(t, map[string]string{"DB_HOST": "localhost"}, result)
+	os.Clearenv()
+}
+
+func TestConfig_ExcludedVars(t *testing.T) {
+	type MyConfig struct {
+		AppName string `conf:"env:APP_NAME"`
+		Region  string `conf:"env:AWS_REGION"`
+		Cluster string `conf:"env:CLUSTER_ID"`
+		Host    string `conf:"env:DB_HOST"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_NAME", "my-app")
+	setenv(t, "AWS_REGION", "us-east-1")
+	setenv(t, "CLUSTER_ID", "on-prem-1")
+	setenv(t, "DB_HOST", "localhost")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	assert.Equal(t, conf.DefaultExcludedVars(), c.ExcludedVars)
+
+	names, err := c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"CLUSTER_ID", "DB_HOST"}, names)
+
+	c.ExcludedVars = []string{"CLUSTER_ID"}
+	names, err = c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_NAME", "AWS_REGION", "DB_HOST"}, names)
+
+	params, err := c.CollectParamsFromEnv("my-app")
+	require.NoError(t, err, "c.CollectParamsFromEnv is not expected to fail")
+	assert.NotContains(t, params, "/my-app/CLUSTER_ID")
+	assert.Equal(t, "us-east-1", params["/my-app/AWS_REGION"])
+
+	report, err := c.EnvReport()
+	require.NoError(t, err, "c.EnvReport is not expected to fail")
+	assert.NotContains(t, report, "CLUSTER_ID")
+
+	c.ExcludedVars = []string{}
+	names, err = c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_NAME", "AWS_REGION", "CLUSTER_ID", "DB_HOST"}, names)
+
+	literal := conf.Config{Data: &config}
+	names, err = literal.EnvNames()
+	require.NoError(t, err, "literal.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"CLUSTER_ID", "DB_HOST"}, names)
+	os.Clearenv()
+}
+
+func TestProcessEnv_RequiredIf(t *testing.T) {
+	type MyConfig struct {
+		TLSEnabled bool   `conf:"env:TLS_ENABLED,default:false"`
+		TLSCert    string `conf:"env:TLS_CERT,required-if:TLS_ENABLED=true"`
+		Mode       string `conf:"env:MODE,default:local"`
+		Endpoint   string `conf:"env:ENDPOINT,required-unless:MODE=local"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	err := conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail when conditions are not met")
+
+	setenv(t, "TLS_ENABLED", "on")
+	setenv(t, "MODE", "remote")
+	config = MyConfig{}
+	err = conf.ProcessEnv(&config)
+	require.Error(t, err, "conf.ProcessEnv is expected to fail when conditions are met")
+	assert.Contains(t, err.Error(), "required key (TLSCert,TLS_CERT) missing value, required-if (TLS_ENABLED=true)")
+	assert.Contains(t, err.Error(), "required key (Endpoint,ENDPOINT) missing value, required-unless (MODE=local)")
+
+	setenv(t, "TLS_CERT", "/etc/tls/cert.pem")
+	setenv(t, "ENDPOINT", "https://example.com")
+	config = MyConfig{}
+	err = conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.True(t, config.TLSEnabled)
+	assert.Equal(t, "/etc/tls/cert.pem", config.TLSCert)
+	assert.Equal(t, "https://example.com", config.Endpoint)
+	os.Clearenv()
+}
+
+func TestProcessCLI_RequiredIf(t *testing.T) {
+	type MyConfig struct {
+		TLSEnabled bool   `conf:"env:TLS_ENABLED,cli:tls,default:false"`
+		TLSCert    string `conf:"env:TLS_CERT,cli:tls-cert,required-if:TLS_ENABLED=true"`
+		Mode       string `conf:"env:MODE,cli:mode,default:local"`
+		Endpoint   string `conf:"env:ENDPOINT,cli:endpoint,required-unless:MODE=local"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	v := viper.New()
+	cmd := &cobra.Command{Use: "my-cmd", Run: func(cmd *cobra.Command, args []string) {}}
+	require.NoError(t, conf.BindCLI(cmd, v, &config))
+	require.NoError(t, conf.ProcessCLI(cmd, v, &config), "conf.ProcessCLI is not expected to fail when conditions are not met")
+
+	cmd.SetArgs([]string{"--tls", "--mode", "remote"})
+	require.NoError(t, cmd.Execute())
+	err := conf.ProcessCLI(cmd, v, &config)
+	require.Error(t, err, "conf.ProcessCLI is expected to fail when conditions are met")
+	assert.Contains(t, err.Error(), "required key (field:TLSCert,env:TLS_CERT,cli:tls-cert) missing value, required-if (TLS_ENABLED=true)")
+	assert.Contains(t, err.Error(), "required key (field:Endpoint,env:ENDPOINT,cli:endpoint) missing value, required-unless (MODE=local)")
+	os.Clearenv()
+}
+
+func TestProcessEnv_EnvAlias(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:DB_HOST,env-alias:OLD_DB_HOST|LEGACY_HOST,required"`
+		Port int    `conf:"env:DB_PORT,env-alias:OLD_DB_PORT"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_LEGACY_HOST", "legacy")
+	setenv(t, "APP_OLD_DB_HOST", "old")
+	setenv(t, "APP_OLD_DB_PORT", "5432")
+
+	var config MyConfig
+	err := conf.ProcessEnv(&config, "APP")
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, "old", config.Host, "aliases are checked in order")
+	assert.Equal(t, 5432, config.Port)
+
+	setenv(t, "APP_DB_HOST", "new")
+	err = conf.ProcessEnv(&config, "APP")
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, "new", config.Host, "the primary name wins over aliases")
+
+	names, err := conf.EnvNames(&config, "APP")
+	require.NoError(t, err, "conf.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HOST", "APP_DB_PORT"}, names)
+
+	names, err = conf.EnvNamesWithAliases(&config, "APP")
+	require.NoError(t, err, "conf.EnvNamesWithAliases is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HOST", "APP_OLD_DB_HOST", "APP_LEGACY_HOST", "APP_DB_PORT", "APP_OLD_DB_PORT"}, names)
+	os.Clearenv()
+}
+
+func TestConfig_PrefixEnvVar(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_HOST", "from-app")
+	setenv(t, "STAGE_HOST", "from-stage")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	c.PrefixEnvVar = "APP_ENV_PREFIX"
+	assert.Equal(t, "APP", c.GetPrefix(), "Prefix is used while the env var is not set")
+
+	setenv(t, "APP_ENV_PREFIX", "STAGE")
+	assert.Equal(t, "STAGE", c.GetPrefix())
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	assert.Equal(t, "from-stage", config.Host)
+
+	names, err := c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"STAGE_HOST"}, names)
+	os.Clearenv()
+}
+
+func TestConfig_WithPrefixFunc(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_HOST", "from-app")
+	setenv(t, "PROD_HOST", "from-prod")
+
+	var config MyConfig
+	calls := 0
+	c := conf.NewConfig(&config, "APP").WithPrefixFunc(func() (string, error) {
+		calls++
+		return strings.ToUpper(os.Getenv("DEPLOY_ENV")), nil
+	})
+	assert.Equal(t, 0, calls, "the prefix is computed lazily")
+	assert.Equal(t, "APP", c.GetPrefix(), "Prefix is used while the func returns empty")
+
+	setenv(t, "DEPLOY_ENV", "prod")
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	assert.Equal(t, "from-prod", config.Host)
+
+	c.PrefixFunc = func() (string, error) {
+		return "", errors.New("no deploy env")
+	}
+	assert.Equal(t, "APP", c.GetPrefix())
+	err := c.ProcessEnv()
+	require.Error(t, err, "c.ProcessEnv is expected to fail")
+	assert.Contains(t, err.Error(), "PrefixFunc failed")
+
+	_, err = c.EnvNames()
+	require.Error(t, err, "c.EnvNames is expected to fail")
+	os.Clearenv()
+}
+
+func TestFields_OnlyFirstPrefixIsUsed(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+
+	var config MyConfig
+	names, err := conf.EnvNames(&config, "APP", "OTHER")
+	require.NoError(t, err, "conf.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_HOST"}, names)
+}
+
+func TestProcessMap(t *testing.T) {
+	t.Parallel()
+
+	type MyConfig struct {
+		Host    string   `conf:"env:HOST,required"`
+		Port    int      `conf:"env:PORT,default:8080"`
+		Hosts   []string `conf:"env:HOSTS"`
+		Old     string   `conf:"env:NEW_NAME,env-alias:OLD_NAME"`
+		Missing string   `conf:"env:MISSING"`
+	}
+
+	values := map[string]string{
+		"APP_HOST":     "localhost",
+		"APP_HOSTS":    "a,b",
+		"APP_OLD_NAME": "old",
+	}
+
+	var config MyConfig
+	err := conf.ProcessMap(values, &config, "APP")
+	require.NoError(t, err, "conf.ProcessMap is not expected to fail")
+	assert.Equal(t, "localhost", config.Host)
+	assert.Equal(t, 8080, config.Port)
+	assert.Equal(t, []string{"a", "b"}, config.Hosts)
+	assert.Equal(t, "old", config.Old)
+	assert.Empty(t, config.Missing)
+
+	err = conf.ProcessMap(map[string]string{"APP_PORT": "abc"}, &MyConfig{}, "APP")
+	require.Error(t, err, "conf.ProcessMap is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Host,APP_HOST) missing value")
+	assert.Contains(t, err.Error(), "ProcessField failed (Port)")
+}
+
+func TestProcessCLI_ViperListsAndMaps(t *testing.T) {
+	type MyConfig struct {
+		Tags   []string          `conf:"env:MY_TAGS,cli:tags"`
+		Ports  []int             `conf:"env:MY_PORTS,cli:ports,delim:|"`
+		Limits map[string]int    `conf:"env:MY_LIMITS,cli:limits"`
+		Labels map[string]string `conf:"env:MY_LABELS,cli:labels,map-pair-sep:;,map-kv-sep:="`
+	}
+
+	yaml := `
+myconfig:
+  tags: [a, b, c]
+  ports:
+    - 80
+    - 443
+  limits:
+    cpu: 2
+    mem: 512
+  labels:
+    team: core
+    env: prod
+`
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(yaml)))
+
+	var config MyConfig
+	cmd := &cobra.Command{Use: "my-cmd"}
+	cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		return conf.ProcessCLI(cmd, v, &config)
+	}
+
+	require.NoError(t, conf.BindCLI(cmd, v, &config))
+	cmd.SetArgs([]string{})
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, []string{"a", "b", "c"}, config.Tags)
+	assert.Equal(t, []int{80, 443}, config.Ports)
+	assert.Equal(t, map[string]int{"cpu": 2, "mem": 512}, config.Limits)
+	assert.Equal(t, map[string]string{"team": "core", "env": "prod"}, config.Labels)
+
+	var fromSource MyConfig
+	err := conf.Process(context.Background(), &fromSource, conf.ViperSource(v))
+	require.NoError(t, err)
+	assert.Equal(t, config, fromSource)
+}
+
+func TestConfig_ProcessEnv_EmptyAsUnset(t *testing.T) {
+	type MyConfig struct {
+		Host  string `conf:"env:MY_HOST,default:localhost"`
+		Token string `conf:"env:MY_TOKEN,required"`
+		Name  string `conf:"env:MY_NAME,env-alias:OLD_NAME"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_HOST", "")
+	setenv(t, "MY_TOKEN", "")
+	setenv(t, "MY_NAME", "")
+	setenv(t, "OLD_NAME", "from-alias")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv(), "empty values are values by default")
+	assert.Equal(t, MyConfig{}, config)
+
+	config = MyConfig{}
+	c = conf.NewConfig(&config)
+	c.EmptyAsUnset = true
+	err := c.ProcessEnv()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key (Token,MY_TOKEN) missing value")
+
+	setenv(t, "MY_TOKEN", "secret")
+	config = MyConfig{}
+	require.NoError(t, c.ProcessEnv())
+	assert.Equal(t, MyConfig{Host: "localhost", Token: "secret", Name: "from-alias"}, config)
+	os.Clearenv()
+}
+
+func TestPointerScalars_TriState(t *testing.T) {
+	type MyConfig struct {
+		Toggle *bool   `conf:"env:MY_TOGGLE,cli:toggle"`
+		Limit  *int    `conf:"env:MY_LIMIT,cli:limit"`
+		Name   *string `conf:"env:MY_NAME,cli:name"`
+	}
+
+	t.Run("env", func(t *testing.T) {
+		os.Clearenv()
+		var config MyConfig
+		require.NoError(t, conf.ProcessEnv(&config))
+		assert.Nil(t, config.Toggle)
+		assert.Nil(t, config.Limit)
+		assert.Nil(t, config.Name)
+
+		setenv(t, "MY_TOGGLE", "false")
+		setenv(t, "MY_LIMIT", "0")
+		require.NoError(t, conf.ProcessEnv(&config))
+		require.NotNil(t, config.Toggle)
+		assert.False(t, *config.Toggle)
+		require.NotNil(t, config.Limit)
+		assert.Equal(t, 0, *config.Limit)
+		assert.Nil(t, config.Name)
+		os.Clearenv()
+	})
+
+	tests := []struct {
+		name   string
+		args   []string
+		toggle *bool
+		limit  *int
+	}{
+		{name: "cli not provided"},
+		{name: "cli set false", args: []string{"--toggle=false"}, toggle: boolPtr(false)},
+		{name: "cli set true", args: []string{"--toggle", "--limit", "5"}, toggle: boolPtr(true), limit: intPtr(5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			v := viper.New()
+			var config MyConfig
+			cmd := &cobra.Command{Use: "my-cmd"}
+			cmd.RunE = func(_ *cobra.Command, _ []string) error {
+				return conf.ProcessCLI(cmd, v, &config)
+			}
+
+			require.NoError(t, conf.BindCLI(cmd, v, &config))
+			assert.Equal(t, "bool", cmd.Flags().Lookup("toggle").Value.Type())
+			assert.Equal(t, "int", cmd.Flags().Lookup("limit").Value.Type())
+			assert.Equal(t, "string", cmd.Flags().Lookup("name").Value.Type())
+
+			cmd.SetArgs(tt.args)
+			require.NoError(t, cmd.Execute())
+			assert.Equal(t, tt.toggle, config.Toggle)
+			assert.Equal(t, tt.limit, config.Limit)
+			assert.Nil(t, config.Name)
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func intPtr(i int) *int { return &i }
+
+func TestEnvNameMap(t *testing.T) {
+	type DBConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+	type MyConfig struct {
+		DB      DBConfig `conf:"prefix:DB"`
+		Port    int      `conf:"env:PORT"`
+		Skipped string   `conf:"env:-"`
+		NoEnv   string
+	}
+
+	names, err := conf.EnvNameMap(&MyConfig{}, "APP")
+	require.NoError(t, err, "conf.EnvNameMap is not expected to fail")
+
+	expected := map[string]conf.EnvName{
+		"DB.Host": {Name: "APP_DB_HOST", Explicit: true},
+		"Port":    {Name: "APP_PORT", Explicit: true},
+	}
+	assert.Equal(t, expected, names)
+}
+
+func TestConfig_EnvNameMap(t *testing.T) {
+	type MyConfig struct {
+		DBHost   string
+		Explicit string `conf:"env:EXPLICIT_VAR"`
+	}
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	c.NameCase = conf.NameCaseUpperSnake
+
+	names, err := c.EnvNameMap()
+	require.NoError(t, err, "c.EnvNameMap is not expected to fail")
+
+	expected := map[string]conf.EnvName{
+		"DBHost":   {Name: "APP_DB_HOST", Explicit: false},
+		"Explicit": {Name: "APP_EXPLICIT_VAR", Explicit: true},
+	}
+	assert.Equal(t, expected, names)
+}
+
+func TestConfig_RequiredEnv(t *testing.T) {
+	type MyConfig struct {
+		SentryDSN string `conf:"env:SENTRY_DSN,required-env:production|staging"`
+		Host      string `conf:"env:HOST,default:localhost"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail without an environment")
+
+	c.Environment = "dev"
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail in dev")
+
+	c.Environment = "production"
+	err := c.ProcessEnv()
+	require.Error(t, err, "c.ProcessEnv is expected to fail in production")
+	assert.Contains(t, err.Error(), "required key (SentryDSN,SENTRY_DSN) missing value, required-env (production)")
+
+	c.Environment = ""
+	c.EnvironmentVar = "APP_ENV"
+	setenv(t, "APP_ENV", "staging")
+	err = c.ProcessEnv()
+	require.Error(t, err, "c.ProcessEnv is expected to fail in staging")
+	assert.Contains(t, err.Error(), "required-env (staging)")
+
+	setenv(t, "SENTRY_DSN", "https://sentry.example.com/1")
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail once set")
+	assert.Equal(t, "https://sentry.example.com/1", config.SentryDSN)
+	os.Clearenv()
+}
+
+func TestProcessEnvStrict(t *testing.T) {
+	type MyConfig struct {
+		Host    string  `conf:"env:HOST"`
+		Port    int     `conf:"env:PORT,default:8080"`
+		Debug   bool    `conf:"env:DEBUG"`
+		Timeout *string `conf:"env:TIMEOUT"`
+	}
+
+	os.Clearenv()
+	setenv(t, "HOST", "localhost")
+
+	var config MyConfig
+	require.NoError(t, conf.ProcessEnv(&config), "conf.ProcessEnv is not expected to fail")
+
+	err := conf.ProcessEnvStrict(&config)
+	require.Error(t, err, "conf.ProcessEnvStrict is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Debug,DEBUG) missing value")
+	assert.Contains(t, err.Error(), "required key (Timeout,TIMEOUT) missing value")
+	assert.NotContains(t, err.Error(), "Host")
+	assert.NotContains(t, err.Error(), "Port")
+
+	setenv(t, "DEBUG", "false")
+	setenv(t, "TIMEOUT", "5s")
+	require.NoError(t, conf.ProcessEnvStrict(&config), "conf.ProcessEnvStrict is not expected to fail")
+
+	c := conf.NewConfig(&MyConfig{}, "APP")
+	err = c.ProcessEnvStrict()
+	require.Error(t, err, "c.ProcessEnvStrict is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Host,APP_HOST) missing value")
+	os.Clearenv()
+}
+
+func TestProcessEnvFields(t *testing.T) {
+	type LimitConfig struct {
+		Rate  int `conf:"env:RATE"`
+		Burst int `conf:"env:BURST,default:10"`
+	}
+	type MyConfig struct {
+		Port    int         `conf:"env:PORT,required"`
+		Limits  LimitConfig `conf:"prefix:LIMIT"`
+		Timeout string      `conf:"env:TIMEOUT,default:${PORT}s"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "8080")
+	setenv(t, "APP_LIMIT_RATE", "100")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "9090")
+	setenv(t, "APP_LIMIT_RATE", "200")
+	setenv(t, "APP_LIMIT_BURST", "20")
+
+	err := conf.ProcessEnvFields(&config, []string{"Rate", "APP_LIMIT_BURST", "Timeout"}, "APP")
+	require.NoError(t, err, "conf.ProcessEnvFields is not expected to fail")
+	assert.Equal(t, 8080, config.Port, "fields not listed are untouched")
+	assert.Equal(t, 200, config.Limits.Rate)
+	assert.Equal(t, 20, config.Limits.Burst)
+	assert.Equal(t, "9090s", config.Timeout, "references still see fields not listed")
+
+	os.Clearenv()
+	setenv(t, "APP_LIMIT_RATE", "300")
+	require.NoError(t, c.ProcessEnvFields("Limits.Rate"), "c.ProcessEnvFields is not expected to fail without the required port")
+	assert.Equal(t, 300, config.Limits.Rate)
+
+	err = c.ProcessEnvFields("Rate", "Missing")
+	require.Error(t, err, "c.ProcessEnvFields is expected to fail")
+	assert.Contains(t, err.Error(), "field (Missing) is not in the spec")
+	os.Clearenv()
+}
+
+func TestConfig_OnField(t *testing.T) {
+	type MyConfig struct {
+		Host  string `conf:"env:HOST,cli:host"`
+		Port  int    `conf:"env:PORT,cli:port,default:8080"`
+		Pass  string `conf:"env:PASS,mask"`
+		Token string `conf:"env:TOKEN,no-print"`
+		Debug bool   `conf:"env:DEBUG"`
+	}
+
+	type event struct {
+		value  string
+		source conf.ValueSource
+	}
+
+	os.Clearenv()
+	setenv(t, "HOST", "localhost")
+	setenv(t, "PASS", "s3cret")
+	setenv(t, "TOKEN", "t0k3n")
+
+	events := map[string]event{}
+	var config MyConfig
+	var hostAtCall string
+	c := conf.NewConfig(&config)
+	c.OnField = func(field conf.Field, value string, source conf.ValueSource) {
+		if field.Path == "Host" {
+			hostAtCall = config.Host
+		}
+		events[field.Path] = event{value: value, source: source}
+	}
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	assert.Empty(t, hostAtCall, "OnField is called before the value is assigned")
+
+	expected := map[string]event{
+		"Host":  {value: "localhost", source: conf.FromEnv},
+		"Port":  {value: "8080", source: conf.FromDefault},
+		"Pass":  {value: conf.MaskedValue, source: conf.FromEnv},
+		"Token": {value: conf.MaskedValue, source: conf.FromEnv},
+		"Debug": {value: "", source: conf.FromNone},
+	}
+	assert.Equal(t, expected, events)
+
+	cmd := &cobra.Command{Use: "my-cmd", Run: func(cmd *cobra.Command, args []string) {}}
+	v := viper.New()
+	require.NoError(t, conf.BindCLI(cmd, v, &config))
+	cmd.SetArgs([]string{"--host", "db.internal"})
+	require.NoError(t, cmd.Execute())
+
+	events = map[string]event{}
+	require.NoError(t, c.ProcessCLI(cmd, v), "c.ProcessCLI is not expected to fail")
+	assert.Equal(t, event{value: "db.internal", source: conf.FromCLI}, events["Host"])
+	assert.Equal(t, event{value: conf.MaskedValue, source: conf.FromEnv}, events["Pass"])
+	assert.Equal(t, event{value: conf.MaskedValue, source: conf.FromEnv}, events["Token"])
+	os.Clearenv()
+}
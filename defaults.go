@@ -0,0 +1,191 @@
+package conf
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// DefaultsPolicy decides which default ProcessEnvWithDefaults uses for a
+// field that has both a default tag and a value in the defaults struct
+type DefaultsPolicy int
+
+const (
+	// TagDefaultsFirst uses the default tag, the struct value is only used
+	// for fields without one
+	TagDefaultsFirst DefaultsPolicy = iota
+
+	// StructDefaultsFirst uses the struct value, the default tag is only
+	// used when the struct value is the zero value
+	StructDefaultsFirst
+)
+
+// ProcessEnvWithDefaults is ProcessEnv with a second source of defaults:
+// defaults, a pointer to a populated struct of the same type as spec. When
+// an env var is not set the field takes its value from defaults, which lets
+// slices, maps and nested structs have defaults written in Go rather than in
+// tag strings. Zero values in defaults mean no default, and a field that gets
+// a default from either place satisfies its required check. Slices, maps and
+// pointers are copied so spec does not share them with defaults.
+func ProcessEnvWithDefaults(defaults, spec interface{}, policy DefaultsPolicy, prefix ...string) error {
+	if reflect.TypeOf(defaults) != reflect.TypeOf(spec) {
+		return failure.System("defaults (%T) and spec (%T) must be the same type", defaults, spec)
+	}
+
+	d := reflect.ValueOf(defaults)
+	if d.Kind() != reflect.Ptr || d.IsNil() || d.Elem().Kind() != reflect.Struct {
+		return InvalidSpecFailure
+	}
+
+	// Fields allocates nil struct pointers, which must not leak into the
+	// caller's defaults
+	work := reflect.New(d.Elem().Type())
+	work.Elem().Set(d.Elem())
+
+	fields, err := Fields(work.Interface(), prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed for defaults")
+	}
+
+	values := make(map[string]reflect.Value, len(fields))
+	for _, field := range fields {
+		if !field.ReflectValue.IsZero() {
+			values[field.Path] = field.ReflectValue
+		}
+	}
+
+	opts := defaultOptions()
+	opts.structFirst = policy == StructDefaultsFirst
+	opts.structDefault = func(field Field) (reflect.Value, bool) {
+		v, ok := values[field.Path]
+		return v, ok
+	}
+
+	return processEnv(spec, opts, prefix...)
+}
+
+// defaultRefPattern matches a ${NAME} reference in a default tag
+var defaultRefPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// resolveDefaultRefs substitutes ${NAME} references in the tag defaults of
+// pending with the resolved values of the fields they name, so
+// default:${BASE_DIR}/logs follows BASE_DIR. NAME is the env var of a field,
+// with or without the prefix, or its path. Referenced defaults are resolved
+// first, a cycle is a failure and names that match no field are left as they
+// are for the expand tag to handle. resolved is updated with the new values.
+func resolveDefaultRefs(pending []resolvedField, resolved map[string]string) error {
+	index := map[string]int{}
+	for i, rf := range pending {
+		if !rf.ok {
+			continue
+		}
+		index[rf.EnvVariable()] = i
+		index[rf.EnvVar] = i
+		index[rf.Path] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(pending))
+
+	var visit func(i int, chain []string) error
+	visit = func(i int, chain []string) error {
+		rf := &pending[i]
+		chain = append(chain, rf.Path)
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return failure.Config("default reference cycle (%s)", strings.Join(chain, " -> "))
+		}
+
+		if rf.source != FromDefault || rf.preset.IsValid() || !strings.Contains(rf.value, "${") {
+			state[i] = done
+			return nil
+		}
+
+		state[i] = visiting
+		var err error
+		value := defaultRefPattern.ReplaceAllStringFunc(rf.value, func(ref string) string {
+			j, ok := index[ref[2:len(ref)-1]]
+			if !ok || err != nil {
+				return ref
+			}
+
+			if err = visit(j, chain); err != nil {
+				return ref
+			}
+
+			return pending[j].value
+		})
+		if err != nil {
+			return err
+		}
+
+		state[i] = done
+		rf.value = value
+		resolved[rf.EnvVariable()] = value
+		resolved[rf.EnvVar] = value
+
+		return nil
+	}
+
+	for i := range pending {
+		if err := visit(i, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setPreset sets dst to a copy of v. dst may be a nil pointer to the type of
+// v, since Fields only dereferences pointers that are already set.
+func setPreset(dst, v reflect.Value) {
+	for dst.Kind() == reflect.Ptr && dst.Type() != v.Type() {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+
+	dst.Set(cloneValue(v))
+}
+
+// cloneValue copies v so that slices, maps and pointers are not shared with
+// the original. Only the top level is copied.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(v.Elem())
+		return c
+	}
+
+	return v
+}
@@ -0,0 +1,134 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type DefaultsDB struct {
+	Host string `conf:"env:HOST,required"`
+	Port int    `conf:"env:PORT,default:5432"`
+}
+
+type DefaultsConfig struct {
+	Hosts   []string          `conf:"env:HOSTS"`
+	Labels  map[string]string `conf:"env:LABELS"`
+	Timeout time.Duration     `conf:"env:TIMEOUT,default:5s"`
+	Retries *int              `conf:"env:RETRIES"`
+	Name    string            `conf:"env:NAME"`
+	DB      *DefaultsDB       `conf:"prefix:DB"`
+}
+
+func newDefaults() *DefaultsConfig {
+	retries := 3
+	return &DefaultsConfig{
+		Hosts:   []string{"a", "b"},
+		Labels:  map[string]string{"team": "core"},
+		Timeout: time.Minute,
+		Retries: &retries,
+		DB:      &DefaultsDB{Host: "db.local", Port: 6543},
+	}
+}
+
+func TestProcessEnvWithDefaults(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "NAME", "from-env")
+	setenv(t, "HOSTS", "x,y")
+
+	tests := []struct {
+		name    string
+		policy  conf.DefaultsPolicy
+		timeout time.Duration
+		port    int
+	}{
+		{name: "tag defaults first", policy: conf.TagDefaultsFirst, timeout: 5 * time.Second, port: 5432},
+		{name: "struct defaults first", policy: conf.StructDefaultsFirst, timeout: time.Minute, port: 6543},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := newDefaults()
+
+			var config DefaultsConfig
+			err := conf.ProcessEnvWithDefaults(defaults, &config, tt.policy)
+			require.NoError(t, err, "conf.ProcessEnvWithDefaults is not expected to fail")
+
+			assert.Equal(t, "from-env", config.Name)
+			assert.Equal(t, []string{"x", "y"}, config.Hosts, "env wins over both defaults")
+			assert.Equal(t, map[string]string{"team": "core"}, config.Labels)
+			assert.Equal(t, tt.timeout, config.Timeout)
+			require.NotNil(t, config.Retries)
+			assert.Equal(t, 3, *config.Retries)
+			require.NotNil(t, config.DB)
+			assert.Equal(t, "db.local", config.DB.Host, "a struct default satisfies required")
+			assert.Equal(t, tt.port, config.DB.Port)
+
+			config.Labels["team"] = "changed"
+			*config.Retries = 10
+			assert.Equal(t, newDefaults(), defaults, "defaults are not shared or modified")
+		})
+	}
+	os.Clearenv()
+}
+
+func TestProcessEnvWithDefaults_Failures(t *testing.T) {
+	os.Clearenv()
+
+	var config DefaultsConfig
+	err := conf.ProcessEnvWithDefaults(&DefaultsConfig{}, &config, conf.TagDefaultsFirst)
+	require.Error(t, err, "conf.ProcessEnvWithDefaults is expected to fail")
+	assert.Contains(t, err.Error(), "required key (DB.Host,DB_HOST) missing value")
+
+	err = conf.ProcessEnvWithDefaults(DefaultsConfig{}, &config, conf.TagDefaultsFirst)
+	require.Error(t, err, "conf.ProcessEnvWithDefaults is expected to fail")
+	assert.Contains(t, err.Error(), "must be the same type")
+}
+
+func TestProcessEnv_DefaultReferences(t *testing.T) {
+	type MyConfig struct {
+		LogDir  string        `conf:"env:LOG_DIR,default:${BASE_DIR}/logs"`
+		BaseDir string        `conf:"env:BASE_DIR,default:/var/app"`
+		Archive string        `conf:"env:ARCHIVE,default:${LOG_DIR}/archive"`
+		URL     string        `conf:"env:URL,default:http://${Host}:${APP_PORT}/api"`
+		Host    string        `conf:"env:HOST,default:localhost"`
+		Port    int           `conf:"env:PORT,default:8080"`
+		Timeout time.Duration `conf:"env:TIMEOUT,default:${BASE_TIMEOUT}"`
+		Base    time.Duration `conf:"env:BASE_TIMEOUT,default:5s"`
+		Literal string        `conf:"env:LITERAL,default:${UNKNOWN}"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "9090")
+
+	var config MyConfig
+	require.NoError(t, conf.ProcessEnv(&config, "APP"))
+	assert.Equal(t, "/var/app/logs", config.LogDir)
+	assert.Equal(t, "/var/app/logs/archive", config.Archive)
+	assert.Equal(t, "http://localhost:9090/api", config.URL)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, "${UNKNOWN}", config.Literal)
+
+	setenv(t, "APP_BASE_DIR", "/opt/app")
+	setenv(t, "APP_LOG_DIR", "/tmp/logs")
+	require.NoError(t, conf.ProcessEnv(&config, "APP"))
+	assert.Equal(t, "/tmp/logs/archive", config.Archive)
+	os.Clearenv()
+}
+
+func TestProcessEnv_DefaultReferenceCycle(t *testing.T) {
+	type MyConfig struct {
+		A string `conf:"env:A,default:${B}"`
+		B string `conf:"env:B,default:${C}/b"`
+		C string `conf:"env:C,default:${A}/c"`
+	}
+
+	os.Clearenv()
+	err := conf.ProcessEnv(&MyConfig{})
+	require.Error(t, err, "conf.ProcessEnv is expected to fail")
+	assert.Contains(t, err.Error(), "default reference cycle (A -> B -> C -> A)")
+}
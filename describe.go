@@ -0,0 +1,44 @@
+package conf
+
+import (
+	"github.com/rsb/failure"
+)
+
+// FieldDoc is the static description of a single config variable, intended
+// for generating documentation. Name is the dotted path of the field, like
+// DB.Host, so nested fields with the same name stay apart.
+type FieldDoc struct {
+	Name     string
+	EnvVar   string
+	Default  string
+	Required bool
+	Masked   bool
+	CLIFlag  string
+	Type     string
+	Doc      string
+}
+
+// Describe returns a FieldDoc for every field in spec, walking embedded
+// structs exactly the way Fields does.
+func Describe(spec interface{}, prefix ...string) ([]FieldDoc, error) {
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	docs := make([]FieldDoc, 0, len(fields))
+	for _, field := range fields {
+		docs = append(docs, FieldDoc{
+			Name:     field.Path,
+			EnvVar:   field.EnvVariable(),
+			Default:  field.DefaultValue(),
+			Required: field.IsRequired(),
+			Masked:   field.IsMasked(),
+			CLIFlag:  field.CLIFlag(),
+			Type:     field.ReflectValue.Type().String(),
+			Doc:      field.Doc(),
+		})
+	}
+
+	return docs, nil
+}
@@ -0,0 +1,84 @@
+package conf_test
+
+import (
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe_Success(t *testing.T) {
+	var config SomeFeatureConfig
+
+	result, err := conf.Describe(&config, "APP")
+	require.NoError(t, err, "conf.Describe is not expected to fail")
+	require.Len(t, result, 10)
+
+	assert.Equal(t, conf.FieldDoc{
+		Name:     "DB.Host",
+		EnvVar:   "APP_CURRICULUM_DB_HOST",
+		Required: true,
+		CLIFlag:  "db-host",
+		Type:     "string",
+	}, result[1])
+
+	assert.Equal(t, conf.FieldDoc{
+		Name:    "DB.Port",
+		EnvVar:  "APP_CURRICULUM_DB_PORT",
+		Default: "5432",
+		Type:    "int",
+	}, result[4])
+
+	assert.Equal(t, "LambdaHandler.AppName", result[8].Name)
+	assert.Equal(t, "FeatureField", result[9].Name)
+}
+
+func TestDescribe_NestedNames(t *testing.T) {
+	type Server struct {
+		Host string `conf:"env:HOST"`
+	}
+	type MyConfig struct {
+		DB    Server `conf:"prefix:DB"`
+		Cache Server `conf:"prefix:CACHE"`
+	}
+
+	result, err := conf.Describe(&MyConfig{})
+	require.NoError(t, err, "conf.Describe is not expected to fail")
+	require.Len(t, result, 2)
+	assert.Equal(t, "DB.Host", result[0].Name)
+	assert.Equal(t, "Cache.Host", result[1].Name)
+}
+
+func TestDescribe_Masked(t *testing.T) {
+	type MyConfig struct {
+		Pass *string `conf:"env:DB_PASS,mask"`
+	}
+
+	var config MyConfig
+	result, err := conf.Describe(&config)
+	require.NoError(t, err, "conf.Describe is not expected to fail")
+	require.Len(t, result, 1)
+	assert.True(t, result[0].Masked)
+	assert.Equal(t, "*string", result[0].Type)
+}
+
+func TestDescribe_Doc(t *testing.T) {
+	type MyConfig struct {
+		Workers int `conf:"env:WORKERS,default:4,doc:Number of concurrent workers, tune based on CPU count"`
+	}
+
+	result, err := conf.Describe(&MyConfig{})
+	require.NoError(t, err, "conf.Describe is not expected to fail")
+	require.Len(t, result, 1)
+	assert.Equal(t, "4", result[0].Default)
+	assert.Equal(t, "Number of concurrent workers, tune based on CPU count", result[0].Doc)
+}
+
+func TestDescribe_FieldsFailure(t *testing.T) {
+	var config InvalidConfigTagParse
+
+	_, err := conf.Describe(&config)
+	require.Error(t, err, "conf.Describe is expected to fail")
+	assert.Contains(t, err.Error(), "Fields failed")
+}
@@ -0,0 +1,105 @@
+package conf
+
+import (
+	"sort"
+
+	"github.com/rsb/failure"
+)
+
+// DiffKind classifies a FieldDiff from the point of view of actual, like a
+// plan: Added means actual is missing the var and Removed means actual has a
+// var the spec would not set.
+type DiffKind int
+
+const (
+	DiffAdded DiffKind = iota + 1
+	DiffRemoved
+	DiffChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// FieldDiff is one env var that differs between the spec and actual. Values
+// of masked and no-print fields are MaskedValue so a plan can be printed
+// safely.
+type FieldDiff struct {
+	Path    string
+	Env     string
+	Kind    DiffKind
+	Desired string
+	Actual  string
+}
+
+// Diff compares the values the spec resolves to, the same values EnvToMap
+// returns, against actual, which is usually the environment or the contents
+// of the param store keyed by env name. Only the env vars of the spec are
+// compared and the result is sorted by env name.
+func Diff(spec interface{}, actual map[string]string, prefix ...string) ([]FieldDiff, error) {
+	desired, err := EnvToMap(spec, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "EnvToMap failed")
+	}
+
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	var result []FieldDiff
+	for _, field := range fields {
+		env := field.EnvVariable()
+		want, ok := desired[env]
+		if !ok {
+			continue
+		}
+
+		// EnvToMap has every field, only those set or with a default
+		// actually have a value
+		_, isSet, err := lookupEnv(field)
+		if err != nil {
+			return nil, failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
+		}
+		hasWant := isSet || field.IsDefault()
+		have, hasActual := actual[env]
+
+		diff := FieldDiff{Path: field.Path, Env: env, Desired: want, Actual: have}
+		switch {
+		case hasWant && !hasActual:
+			diff.Kind = DiffAdded
+		case !hasWant && hasActual:
+			diff.Kind = DiffRemoved
+		case hasWant && want != have:
+			diff.Kind = DiffChanged
+		default:
+			continue
+		}
+
+		if field.IsMasked() || field.IsNoPrint() {
+			if diff.Desired != "" {
+				diff.Desired = MaskedValue
+			}
+			if diff.Actual != "" {
+				diff.Actual = MaskedValue
+			}
+		}
+
+		result = append(result, diff)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Env < result[j].Env
+	})
+
+	return result, nil
+}
@@ -0,0 +1,64 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	type MyConfig struct {
+		Host     string `conf:"env:DB_HOST"`
+		Port     int    `conf:"env:DB_PORT,default:5432"`
+		User     string `conf:"env:DB_USER,default:admin"`
+		Pass     string `conf:"env:DB_PASS,mask"`
+		Token    string `conf:"env:TOKEN,no-print"`
+		Optional string `conf:"env:OPTIONAL"`
+		Same     string `conf:"env:SAME,default:x"`
+	}
+
+	os.Clearenv()
+	setenv(t, "DB_HOST", "new-host")
+	setenv(t, "DB_PASS", "new-secret")
+	setenv(t, "TOKEN", "new-token")
+
+	actual := map[string]string{
+		"DB_HOST":   "old-host",
+		"DB_PASS":   "old-secret",
+		"OPTIONAL":  "stale",
+		"SAME":      "x",
+		"UNRELATED": "ignored",
+		"DB_USER":   "admin",
+		"TOKEN":     "old-token",
+	}
+
+	var config MyConfig
+	result, err := conf.Diff(&config, actual)
+	require.NoError(t, err)
+
+	expected := []conf.FieldDiff{
+		{Path: "Host", Env: "DB_HOST", Kind: conf.DiffChanged, Desired: "new-host", Actual: "old-host"},
+		{Path: "Pass", Env: "DB_PASS", Kind: conf.DiffChanged, Desired: conf.MaskedValue, Actual: conf.MaskedValue},
+		{Path: "Port", Env: "DB_PORT", Kind: conf.DiffAdded, Desired: "5432"},
+		{Path: "Optional", Env: "OPTIONAL", Kind: conf.DiffRemoved, Actual: "stale"},
+		{Path: "Token", Env: "TOKEN", Kind: conf.DiffChanged, Desired: conf.MaskedValue, Actual: conf.MaskedValue},
+	}
+	assert.Equal(t, expected, result)
+	assert.Equal(t, "changed", conf.DiffChanged.String())
+	os.Clearenv()
+}
+
+func TestDiff_RequiredFailure(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:DB_HOST,required"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	_, err := conf.Diff(&config, map[string]string{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key (Host,DB_HOST) missing value")
+}
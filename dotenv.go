@@ -0,0 +1,208 @@
+package conf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// ProcessEnvFile loads the dotenv file at path into the process environment
+// and then runs ProcessEnv. Variables that are already set in the real
+// environment are never overwritten, so the environment always takes
+// precedence over the file.
+func ProcessEnvFile(path string, spec interface{}, prefix ...string) error {
+	if err := LoadEnvFile(path); err != nil {
+		return failure.Wrap(err, "LoadEnvFile failed")
+	}
+
+	if err := ProcessEnv(spec, prefix...); err != nil {
+		return failure.Wrap(err, "ProcessEnv failed")
+	}
+
+	return nil
+}
+
+// LoadEnvFile sets every variable in the dotenv file at path that is not
+// already present in the process environment.
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return failure.ToSystem(err, "os.Open failed (%s)", path)
+	}
+	defer file.Close()
+
+	values, err := ParseDotEnv(file)
+	if err != nil {
+		return failure.Wrap(err, "ParseDotEnv failed (%s)", path)
+	}
+
+	for key, value := range values {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+
+		if err = os.Setenv(key, value); err != nil {
+			return failure.ToSystem(err, "os.Setenv failed (%s)", key)
+		}
+	}
+
+	return nil
+}
+
+// ProcessReader is ProcessMap with the values parsed from r by ParseDotEnv,
+// so KEY=VALUE lines, blank lines and # comments are accepted, as is the
+// output of DumpEnv. Unlike ProcessEnvFile the process environment is never
+// read or changed.
+func ProcessReader(r io.Reader, spec interface{}, prefix ...string) error {
+	values, err := ParseDotEnv(r)
+	if err != nil {
+		return failure.Wrap(err, "ParseDotEnv failed")
+	}
+
+	if err = ProcessMap(values, spec, prefix...); err != nil {
+		return failure.Wrap(err, "ProcessMap failed")
+	}
+
+	return nil
+}
+
+// WriteEnvTemplate writes a dotenv file to w with one KEY= line for every env
+// var spec reads, in field order. Defaults are filled in, required fields
+// without a default get a "# required" comment and masked fields get a
+// "# secret" comment and are always left empty.
+func WriteEnvTemplate(w io.Writer, spec interface{}, prefix ...string) error {
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		env := field.EnvVariable()
+		if env == "" {
+			continue
+		}
+
+		var value string
+		switch {
+		case field.IsMasked():
+			b.WriteString("# secret\n")
+		case field.IsDefault():
+			value = dotEnvQuote(field.DefaultValue())
+		case field.IsRequired():
+			b.WriteString("# required\n")
+		}
+
+		fmt.Fprintf(&b, "%s=%s\n", env, value)
+	}
+
+	if _, err = io.WriteString(w, b.String()); err != nil {
+		return failure.ToSystem(err, "io.WriteString failed")
+	}
+
+	return nil
+}
+
+// dotEnvQuote double quotes a value when ParseDotEnv would otherwise read it
+// differently, such as values with spaces, quotes or a # comment marker
+func dotEnvQuote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"'#\\") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// ParseDotEnv reads dotenv formatted lines. It supports KEY=VALUE and
+// export KEY=VALUE, blank lines and # comments. Double-quoted values may use
+// the usual escapes (\n, \t, \", \\), single-quoted values are taken
+// literally and unquoted values may end in a # comment.
+func ParseDotEnv(r io.Reader) (map[string]string, error) {
+	result := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	lineNbr := 0
+	for scanner.Scan() {
+		lineNbr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return result, failure.Config("invalid dotenv line (%d): missing '='", lineNbr)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return result, failure.Config("invalid dotenv line (%d): empty key", lineNbr)
+		}
+
+		value, err := dotEnvValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return result, failure.Wrap(err, "invalid dotenv line (%d)", lineNbr)
+		}
+
+		result[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result, failure.ToSystem(err, "scanner.Scan failed")
+	}
+
+	return result, nil
+}
+
+func dotEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return raw, nil
+	}
+
+	switch raw[0] {
+	case '"':
+		end := closingQuote(raw, '"')
+		if end < 0 {
+			return "", failure.Config("unterminated double quote")
+		}
+		value, err := strconv.Unquote(raw[:end+1])
+		if err != nil {
+			return "", failure.ToConfig(err, "strconv.Unquote failed")
+		}
+		return value, nil
+	case '\'':
+		end := closingQuote(raw, '\'')
+		if end < 0 {
+			return "", failure.Config("unterminated single quote")
+		}
+		return raw[1:end], nil
+	}
+
+	if idx := strings.Index(raw, " #"); idx >= 0 {
+		raw = raw[:idx]
+	}
+
+	return strings.TrimSpace(raw), nil
+}
+
+// closingQuote returns the index of the quote that closes the one at the
+// start of raw, skipping escaped quotes for double-quoted values
+func closingQuote(raw string, quote byte) int {
+	for i := 1; i < len(raw); i++ {
+		if quote == '"' && raw[i] == '\\' {
+			i++
+			continue
+		}
+		if raw[i] == quote {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,156 @@
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotEnv_Success(t *testing.T) {
+	content := `
+# a comment
+FOO=bar
+export EXPORTED=yes
+SPACES = some value # trailing comment
+DOUBLE="hello \"world\"\nnext"
+SINGLE='literal \n # not a comment'
+EMPTY=
+HASH=abc#def
+`
+
+	result, err := conf.ParseDotEnv(strings.NewReader(content))
+	require.NoError(t, err, "conf.ParseDotEnv is not expected to fail")
+
+	expected := map[string]string{
+		"FOO":      "bar",
+		"EXPORTED": "yes",
+		"SPACES":   "some value",
+		"DOUBLE":   "hello \"world\"\nnext",
+		"SINGLE":   `literal \n # not a comment`,
+		"EMPTY":    "",
+		"HASH":     "abc#def",
+	}
+	assert.Equal(t, expected, result)
+}
+
+func TestParseDotEnv_Failures(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		msg     string
+	}{
+		{
+			name:    "missing equals",
+			content: "FOO",
+			msg:     "invalid dotenv line (1): missing '='",
+		},
+		{
+			name:    "empty key",
+			content: "\n=bar",
+			msg:     "invalid dotenv line (2): empty key",
+		},
+		{
+			name:    "unterminated quote",
+			content: `FOO="bar`,
+			msg:     "unterminated double quote",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := conf.ParseDotEnv(strings.NewReader(tt.content))
+			require.Error(t, err, "conf.ParseDotEnv is expected to fail")
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestProcessEnvFile_EnvTakesPrecedence(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,required"`
+		Port int    `conf:"env:PORT,required"`
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("APP_HOST=file-host\nAPP_PORT=8080\n"), 0600))
+
+	os.Clearenv()
+	setenv(t, "APP_HOST", "env-host")
+
+	var config MyConfig
+	err := conf.ProcessEnvFile(path, &config, "APP")
+	require.NoError(t, err, "conf.ProcessEnvFile is not expected to fail")
+	assert.Equal(t, "env-host", config.Host)
+	assert.Equal(t, 8080, config.Port)
+	os.Clearenv()
+}
+
+func TestProcessEnvFile_MissingFile(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+
+	var config MyConfig
+	err := conf.ProcessEnvFile(filepath.Join(t.TempDir(), "missing.env"), &config)
+	require.Error(t, err, "conf.ProcessEnvFile is expected to fail")
+	assert.Contains(t, err.Error(), "os.Open failed")
+}
+
+func TestProcessReader(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,required"`
+		Port int    `conf:"env:PORT,default:8080"`
+		Name string `conf:"env:NAME"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "1")
+	input := "# generated\n\nAPP_HOST=localhost\nexport APP_NAME='my app'\n"
+
+	var config MyConfig
+	err := conf.ProcessReader(strings.NewReader(input), &config, "APP")
+	require.NoError(t, err, "conf.ProcessReader is not expected to fail")
+	assert.Equal(t, MyConfig{Host: "localhost", Port: 8080, Name: "my app"}, config)
+
+	err = conf.ProcessReader(strings.NewReader("APP_PORT=9090\n"), &MyConfig{}, "APP")
+	require.Error(t, err, "conf.ProcessReader is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Host,APP_HOST) missing value")
+
+	err = conf.ProcessReader(strings.NewReader("APP_HOST\n"), &MyConfig{}, "APP")
+	require.Error(t, err, "conf.ProcessReader is expected to fail")
+	assert.Contains(t, err.Error(), "missing '='")
+	os.Clearenv()
+}
+
+func TestWriteEnvTemplate(t *testing.T) {
+	type MyConfig struct {
+		Host    string `conf:"env:HOST,required"`
+		Port    int    `conf:"env:PORT,default:8080"`
+		Greet   string `conf:"env:GREETING,default:hello world"`
+		Pass    string `conf:"env:PASS,mask,required"`
+		Timeout string `conf:"env:TIMEOUT"`
+	}
+
+	var config MyConfig
+	var b strings.Builder
+	err := conf.WriteEnvTemplate(&b, &config, "APP")
+	require.NoError(t, err, "conf.WriteEnvTemplate is not expected to fail")
+
+	expected := "# required\n" +
+		"APP_HOST=\n" +
+		"APP_PORT=8080\n" +
+		"APP_GREETING=\"hello world\"\n" +
+		"# secret\n" +
+		"APP_PASS=\n" +
+		"APP_TIMEOUT=\n"
+	assert.Equal(t, expected, b.String())
+
+	result, err := conf.ParseDotEnv(strings.NewReader(b.String()))
+	require.NoError(t, err, "conf.ParseDotEnv is not expected to fail")
+	assert.Equal(t, "hello world", result["APP_GREETING"])
+}
@@ -0,0 +1,77 @@
+package conf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// DumpEnv renders the resolved environment of spec as shell export
+// statements, one per line and sorted by name. Values are resolved exactly
+// like EnvToMap. Unless includeSecrets is true, fields tagged mask are shown
+// as MaskedValue and fields tagged no-print are left out.
+func DumpEnv(spec interface{}, includeSecrets bool, prefix ...string) (string, error) {
+	return dumpEnv(spec, includeSecrets, defaultOptions(), prefix...)
+}
+
+func dumpEnv(spec interface{}, includeSecrets bool, opts options, prefix ...string) (string, error) {
+	values, err := envToMap(spec, opts, prefix...)
+	if err != nil {
+		return "", failure.Wrap(err, "EnvToMap failed")
+	}
+
+	if !includeSecrets {
+		fields, err := specFields(spec, opts, prefix...)
+		if err != nil {
+			return "", failure.Wrap(err, "Fields failed")
+		}
+
+		redactValues(values, claimEnvVars(fields), false)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(values[k]))
+	}
+
+	return b.String(), nil
+}
+
+// shellQuote single quotes a value unless it is made up entirely of
+// characters the shell treats literally
+func shellQuote(value string) string {
+	if value == "" {
+		return "''"
+	}
+
+	safe := true
+	for _, r := range value {
+		if !isShellSafe(r) {
+			safe = false
+			break
+		}
+	}
+
+	if safe {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func isShellSafe(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+
+	return strings.ContainsRune("_-.,:/@%+=", r)
+}
@@ -0,0 +1,59 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type DumpConfig struct {
+	Host    string `conf:"env:DB_HOST"`
+	Port    int    `conf:"env:DB_PORT,default:5432"`
+	Pass    string `conf:"env:DB_PASS,mask"`
+	Token   string `conf:"env:API_TOKEN,no-print"`
+	Message string `conf:"env:MESSAGE"`
+	AppName string `conf:"env:APP_NAME"`
+}
+
+func TestDumpEnv_Redacted(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "DB_HOST", "localhost")
+	setenv(t, "DB_PASS", "s3cret")
+	setenv(t, "API_TOKEN", "token")
+	setenv(t, "MESSAGE", "it's a $test")
+	setenv(t, "APP_NAME", "my-app")
+
+	var config DumpConfig
+	result, err := conf.DumpEnv(&config, false)
+	require.NoError(t, err, "conf.DumpEnv is not expected to fail")
+
+	expected := "export DB_HOST=localhost\n" +
+		"export DB_PASS='****'\n" +
+		"export DB_PORT=5432\n" +
+		"export MESSAGE='it'\\''s a $test'\n"
+	assert.Equal(t, expected, result)
+	os.Clearenv()
+}
+
+func TestDumpEnv_IncludeSecrets(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "APP_DB_PASS", "s3cret")
+	setenv(t, "APP_API_TOKEN", "token")
+
+	var config DumpConfig
+	c := conf.NewConfig(&config, "APP")
+	result, err := c.DumpEnv(true)
+	require.NoError(t, err, "c.DumpEnv is not expected to fail")
+
+	expected := "export APP_API_TOKEN=token\n" +
+		"export APP_APP_NAME=''\n" +
+		"export APP_DB_HOST=''\n" +
+		"export APP_DB_PASS=s3cret\n" +
+		"export APP_DB_PORT=5432\n" +
+		"export APP_MESSAGE=''\n"
+	assert.Equal(t, expected, result)
+	os.Clearenv()
+}
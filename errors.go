@@ -0,0 +1,61 @@
+package conf
+
+import "errors"
+
+// Reasons a ConfigError can have
+const (
+	// ReasonMissing is a required field that nothing had a value for
+	ReasonMissing = "missing"
+
+	// ReasonInvalid is a value that could not be converted or failed a
+	// check like oneof, min or regex
+	ReasonInvalid = "invalid"
+
+	// ReasonLookup is a source that failed while looking the field up
+	ReasonLookup = "lookup"
+)
+
+// ConfigError is the failure of a single field. Processing collects them in
+// a failure.Multi, use errors.As to get the first one or ConfigErrors to get
+// all of them:
+//
+//	var ce *conf.ConfigError
+//	if errors.As(err, &ce) && ce.Reason == conf.ReasonMissing {
+//		log.Printf("set %s", ce.EnvVar)
+//	}
+type ConfigError struct {
+	Field  string
+	EnvVar string
+	Reason string
+	Err    error
+}
+
+func newConfigError(field Field, reason string, err error) *ConfigError {
+	return &ConfigError{Field: field.Path, EnvVar: field.EnvVariable(), Reason: reason, Err: err}
+}
+
+// Error is the message of Err, so wrapping a failure in a ConfigError does
+// not change how it reads
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors returns every ConfigError in err, in the order they were
+// collected
+func ConfigErrors(err error) []*ConfigError {
+	var result []*ConfigError
+	seen := map[*ConfigError]bool{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		var ce *ConfigError
+		if errors.As(err, &ce) && !seen[ce] {
+			seen[ce] = true
+			result = append(result, ce)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,46 @@
+package conf_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigError(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,required"`
+		Port int    `conf:"env:PORT"`
+		Key  string `conf:"env:KEY,required"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "abc")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	err := c.ProcessEnv()
+	require.Error(t, err, "c.ProcessEnv is expected to fail")
+	assert.True(t, failure.IsConfig(err), "the failure kind is kept")
+
+	var ce *conf.ConfigError
+	require.True(t, errors.As(err, &ce), "errors.As is expected to find a ConfigError")
+	assert.Equal(t, "Host", ce.Field)
+	assert.Equal(t, "APP_HOST", ce.EnvVar)
+	assert.Equal(t, conf.ReasonMissing, ce.Reason)
+	assert.Contains(t, ce.Error(), "required key (Host,APP_HOST) missing value")
+
+	all := conf.ConfigErrors(err)
+	require.Len(t, all, 3)
+	assert.Equal(t, []string{"Host", "Port", "Key"}, []string{all[0].Field, all[1].Field, all[2].Field})
+	assert.Equal(t, conf.ReasonInvalid, all[1].Reason)
+	assert.Contains(t, all[1].Error(), "strconv.ParseInt failed")
+	assert.Equal(t, conf.ReasonMissing, all[2].Reason)
+
+	assert.Empty(t, conf.ConfigErrors(errors.New("other")))
+	os.Clearenv()
+}
@@ -0,0 +1,4 @@
+package conf
+
+// ResetLayoutCache lets tests and benchmarks in conf_test parse tags again
+var ResetLayoutCache = resetLayoutCache
@@ -2,35 +2,72 @@ package conf
 
 import (
 	"encoding"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/rsb/failure"
 )
 
+const (
+	DefaultSliceDelimiter   = ","
+	DefaultMapPairSeparator = ","
+	DefaultMapKVSeparator   = ":"
+)
+
 var (
 	InvalidSpecFailure = failure.Config("specification must be a struct pointer")
+
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP{})
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	urlType      = reflect.TypeOf(url.URL{})
 )
 
 // Field holds information about the current configuration variable
 type Field struct {
 	StructName   string
 	Name         string
+	Path         string
 	Prefix       string
 	EnvVar       string
 	ReflectValue reflect.Value
 	ReflectTag   reflect.StructTag
 	bindName     string
 	Tag          Tag
+
+	// derived is set when EnvVar came from the NameCase of a Config rather
+	// than the env tag
+	derived bool
+
+	// bounds holds the min and max tags parsed for the field's type
+	bounds bounds
 }
 
 func (f Field) BindName() string {
 	return f.bindName
 }
 
+// IsEnvDerived reports whether the env name was derived from the field name,
+// see Config.NameCase, rather than given by the env tag
+func (f Field) IsEnvDerived() bool {
+	return f.derived
+}
+
+// EnvVariable is the env var of the field with its prefix. Fields tagged
+// no-prefix never get one, whether their name comes from the env tag or is
+// derived by Config.NameCase.
 func (f Field) EnvVariable() string {
 	if f.Tag.NoPrefix {
 		return f.EnvVar
@@ -43,10 +80,64 @@ func (f Field) EnvVariable() string {
 	return f.EnvVar
 }
 
+// EnvAliases are the env-alias names, prefixed the same way as
+// EnvVariable, that are checked in order when EnvVariable is not set
+func (f Field) EnvAliases() []string {
+	result := make([]string, 0, len(f.Tag.EnvAliases))
+	for _, alias := range f.Tag.EnvAliases {
+		if f.Prefix != "" && !f.Tag.NoPrefix {
+			alias = fmt.Sprintf("%s_%s", f.Prefix, alias)
+		}
+		result = append(result, alias)
+	}
+
+	return result
+}
+
+// IsFromFile reports whether the value may be read from the file named by
+// the companion <ENV>_FILE variable
+func (f Field) IsFromFile() bool {
+	return f.Tag.FromFile
+}
+
+// FileEnvVariable is the companion variable holding the path of the file to
+// read the value from when the from-file tag is used
+func (f Field) FileEnvVariable() string {
+	return f.EnvVariable() + "_FILE"
+}
+
 func (f Field) IsRequired() bool {
 	return f.Tag.Required
 }
 
+// IsRequiredIn reports whether the required-env tag lists environment, an
+// empty environment never matches
+func (f Field) IsRequiredIn(environment string) bool {
+	if environment == "" {
+		return false
+	}
+
+	for _, name := range f.Tag.RequiredEnv {
+		if name == environment {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsMasked reports whether the field is tagged mask or is nested in a
+// struct field tagged mask
+func (f Field) IsMasked() bool {
+	return f.Tag.Mask
+}
+
+// IsNoPrint reports whether the field is tagged no-print or is nested in a
+// struct field tagged no-print
+func (f Field) IsNoPrint() bool {
+	return f.Tag.NoPrint
+}
+
 func (f Field) ParamStoreKey() string {
 	return f.Tag.PStoreVar
 }
@@ -59,6 +150,66 @@ func (f Field) IsGlobalParamStore() bool {
 	return f.Tag.IsPStoreGlobal
 }
 
+func (f Field) IsPStoreSecure() bool {
+	return f.Tag.IsPStoreSecure
+}
+
+// JSONKey is the key used to find the field in a JSON object, it is the json
+// tag when present otherwise the env variable.
+func (f Field) JSONKey() string {
+	if f.Tag.JSONKey != "" {
+		return f.Tag.JSONKey
+	}
+
+	return f.EnvVariable()
+}
+
+// TOMLKey is the key used to find the field in a TOML table, it is the toml
+// tag when present otherwise the env variable.
+func (f Field) TOMLKey() string {
+	if f.Tag.TOMLKey != "" {
+		return f.Tag.TOMLKey
+	}
+
+	return f.EnvVariable()
+}
+
+// YAMLKey is the key used to find the field in a YAML mapping, it is the
+// yaml tag when present otherwise the env variable.
+func (f Field) YAMLKey() string {
+	if f.Tag.YAMLKey != "" {
+		return f.Tag.YAMLKey
+	}
+
+	return f.EnvVariable()
+}
+
+// VaultKey is the key used to find the field in the data of a Vault secret,
+// it is the vault tag when present otherwise the env variable.
+func (f Field) VaultKey() string {
+	if f.Tag.VaultKey != "" {
+		return f.Tag.VaultKey
+	}
+
+	return f.EnvVariable()
+}
+
+// SecretKey is the key used to find the field in a Secrets Manager secret,
+// it is the secret tag when present otherwise the env variable.
+func (f Field) SecretKey() string {
+	if f.Tag.SecretKey != "" {
+		return f.Tag.SecretKey
+	}
+
+	return f.EnvVariable()
+}
+
+// IsSecret reports whether the field is resolved by ProcessWithSource, which
+// is when it is tagged mask, pstore, pstore-secure or secret
+func (f Field) IsSecret() bool {
+	return f.IsMasked() || f.Tag.PStoreVar != "" || f.IsPStoreSecure() || f.Tag.SecretKey != ""
+}
+
 func (f Field) CLIFlag() string {
 	return f.Tag.CLIFlag
 }
@@ -68,6 +219,8 @@ func (f Field) IsCLI() bool {
 	return flag != "" && flag != "-" && !f.Tag.NoCLIBind
 }
 
+// IsPersistentFlag reports whether BindCLI registers the flag with
+// PersistentFlags rather than Flags, see the flag-scope tag
 func (f Field) IsPersistentFlag() bool {
 	return f.Tag.IsCLIPFlag
 }
@@ -80,6 +233,81 @@ func (f Field) CLIUsage() string {
 	return f.Tag.CLIUsage
 }
 
+// Doc is the longer description from the doc tag
+func (f Field) Doc() string {
+	return f.Tag.Doc
+}
+
+// DocInUsage makes BindCLI append the doc tag to the flag usage. The doc is
+// always used when there is no cli-u tag.
+var DocInUsage = false
+
+// flagUsage is the usage BindCLI registers the flag with
+func (f Field) flagUsage() string {
+	usage, doc := f.CLIUsage(), f.Doc()
+	switch {
+	case doc == "":
+		return usage
+	case usage == "":
+		return doc
+	case DocInUsage:
+		return usage + ". " + doc
+	}
+
+	return usage
+}
+
+// IsHiddenFlag reports whether the flag is left out of the help output, it
+// is still accepted on the command line
+func (f Field) IsHiddenFlag() bool {
+	return f.Tag.Hidden
+}
+
+// CLIDeprecated is the message cobra prints when a deprecated flag is used,
+// it is empty unless the flag is deprecated
+func (f Field) CLIDeprecated() string {
+	return f.Tag.Deprecated
+}
+
+// CLIMutexGroup names the set of flags that can not be used together
+func (f Field) CLIMutexGroup() string {
+	return f.Tag.MutexGroup
+}
+
+// ArgIndex is the position of the field in the positional args given to
+// ProcessArgs, the bool is false when the field is not an arg
+func (f Field) ArgIndex() (int, bool) {
+	return f.Tag.ArgIndex, f.Tag.IsArg
+}
+
+// IsArg reports whether the field is tagged arg:N. Arg fields are only set by
+// ProcessArgs, every other Process function skips them.
+func (f Field) IsArg() bool {
+	return f.Tag.IsArg
+}
+
+// CLIGroup is the name of the group the flag is listed under in the usage
+// output of BindCLIWithGroups
+func (f Field) CLIGroup() string {
+	return f.Tag.Group
+}
+
+// IsCLIFor reports whether the flag is registered for the command named
+// name, which is every command unless the scope-cmds tag lists them
+func (f Field) IsCLIFor(name string) bool {
+	if len(f.Tag.ScopeCmds) == 0 {
+		return true
+	}
+
+	for _, c := range f.Tag.ScopeCmds {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (f Field) IsDefault() bool {
 	return f.Tag.IsDefault
 }
@@ -88,7 +316,56 @@ func (f Field) DefaultValue() string {
 	return f.Tag.Default
 }
 
+// SliceDelimiter is the separator used to split slice values, it defaults to
+// a comma when the delim tag is not used.
+func (f Field) SliceDelimiter() string {
+	if f.Tag.Delimiter == "" {
+		return DefaultSliceDelimiter
+	}
+
+	return f.Tag.Delimiter
+}
+
+// IsIndexed reports whether a slice may also be read from the numbered
+// variables <ENV>_0, <ENV>_1 and so on, see the indexed tag
+func (f Field) IsIndexed() bool {
+	return f.Tag.Indexed
+}
+
+// IndexedEnvVariable is the numbered variable holding item i of an indexed
+// slice
+func (f Field) IndexedEnvVariable(i int) string {
+	return fmt.Sprintf("%s_%d", f.EnvVariable(), i)
+}
+
+// MapPairSeparator is the separator used between the items of a map value,
+// it defaults to a comma when the map-pair-sep tag is not used.
+func (f Field) MapPairSeparator() string {
+	if f.Tag.MapPairSep == "" {
+		return DefaultMapPairSeparator
+	}
+
+	return f.Tag.MapPairSep
+}
+
+// MapKVSeparator is the separator used between the key and value of a map
+// item, it defaults to a colon when the map-kv-sep tag is not used.
+func (f Field) MapKVSeparator() string {
+	if f.Tag.MapKVSep == "" {
+		return DefaultMapKVSeparator
+	}
+
+	return f.Tag.MapKVSep
+}
+
+// Fields returns the fields of spec that can be configured. The prefix is
+// variadic only so it can be left out, just the first one is used and any
+// others are ignored.
 func Fields(spec interface{}, prefixParam ...string) ([]Field, error) {
+	return specFields(spec, defaultOptions(), prefixParam...)
+}
+
+func specFields(spec interface{}, opts options, prefixParam ...string) ([]Field, error) {
 	var prefix string
 	var fields []Field
 	s := reflect.ValueOf(spec)
@@ -106,29 +383,39 @@ func Fields(spec interface{}, prefixParam ...string) ([]Field, error) {
 		prefix = prefixParam[0]
 	}
 
-	specType := s.Type()
+	return structFields(s, opts, prefix, "")
+}
 
-	structName := reflect.TypeOf(spec).Elem().Name()
-	for i := 0; i < s.NumField(); i++ {
-		f := s.Field(i)
-		ftype := specType.Field(i)
+// structFields collects the fields of the struct s, path is the dotted path
+// of s from the spec, like PrimaryDB, and is empty for the spec itself.
+func structFields(s reflect.Value, opts options, prefix, path string) ([]Field, error) {
+	var fields []Field
+	layout, err := structLayout(s.Type())
+	if err != nil {
+		return fields, err
+	}
 
-		confTags := ftype.Tag.Get("conf")
-		if !f.CanSet() || confTags == "-" {
+	structName := s.Type().Name()
+	for _, sf := range layout {
+		f := s.Field(sf.index)
+		if !f.CanSet() {
 			continue
 		}
 
+		ftype := sf.field
 		fieldName := ftype.Name
-
-		fieldOpts, err := ParseTag(confTags)
-		if err != nil {
-			return fields, failure.Wrap(err, "parseTag failed (%s)", fieldName)
+		fieldOpts := sf.tag
+		derived := false
+		if fieldOpts.EnvVar == "" {
+			fieldOpts.EnvVar = opts.nameCase.envName(fieldName, opts.camelWords)
+			derived = fieldOpts.EnvVar != ""
 		}
 
 		for f.Kind() == reflect.Ptr {
 			if f.IsNil() {
-				if f.Type().Elem().Kind() != reflect.Struct {
-					// nil pointer to a non-struct: leave it alone
+				if f.Type().Elem().Kind() != reflect.Struct || isKnownType(f.Type().Elem()) {
+					// nil pointer to a non-struct or to a type we process as a
+					// single value: leave it alone
 					break
 				}
 				// nil pointer to a struct: create a zero instance
@@ -139,22 +426,31 @@ func Fields(spec interface{}, prefixParam ...string) ([]Field, error) {
 
 		switch {
 		case f.Kind() == reflect.Struct:
-			if DecoderFrom(f) == nil && SetterFrom(f) == nil && TextUnmarshaler(f) == nil && BinaryUnmarshaler(f) == nil {
-				innerPrefix := []string{prefix}
-				embeddedPtr := f.Addr().Interface()
-				innerFields, err := Fields(embeddedPtr, innerPrefix...)
+			if !isValueType(f) {
+				innerPath := joinPath(path, fieldName)
+				innerFields, err := structFields(f, opts, joinPrefix(prefix, fieldOpts.Prefix), innerPath)
 				if err != nil {
-					return fields, failure.Wrap(err, "Fields failed for embedded struct")
+					return fields, failure.Wrap(err, "Fields failed for embedded struct (%s)", innerPath)
 				}
+				inheritRedaction(innerFields, fieldOpts)
 				fields = append(fields, innerFields...)
 				continue
 			}
 
 			data := NewField(fieldName, prefix, structName, f, ftype.Tag, fieldOpts)
+			data.Path = joinPath(path, fieldName)
+			data.derived = derived
+			data.bounds = sf.bounds
 			fields = append(fields, data)
 
 		default:
+			if err = validateDefault(f, fieldOpts); err != nil {
+				return fields, failure.Wrap(err, "validateDefault failed (%s)", joinPath(path, fieldName))
+			}
 			data := NewField(fieldName, prefix, structName, f, ftype.Tag, fieldOpts)
+			data.Path = joinPath(path, fieldName)
+			data.derived = derived
+			data.bounds = sf.bounds
 			fields = append(fields, data)
 		}
 
@@ -163,6 +459,148 @@ func Fields(spec interface{}, prefixParam ...string) ([]Field, error) {
 	return fields, nil
 }
 
+// inheritRedaction passes mask and no-print from the tag of a struct field
+// down to every field nested under it, so a struct of credentials tagged
+// mask is redacted as a whole. A nested field can add either tag but can not
+// remove one it inherits.
+func inheritRedaction(fields []Field, parent Tag) {
+	for i := range fields {
+		fields[i].Tag.Mask = fields[i].Tag.Mask || parent.Mask
+		fields[i].Tag.NoPrint = fields[i].Tag.NoPrint || parent.NoPrint
+	}
+}
+
+// layoutField is the part of a struct field that is the same for every
+// instance of the struct, ReflectValue is not part of it
+type layoutField struct {
+	index  int
+	field  reflect.StructField
+	tag    Tag
+	bounds bounds
+}
+
+type layoutKey struct {
+	typ    reflect.Type
+	strict bool
+}
+
+// layoutCache holds the []layoutField of each struct type Fields has seen,
+// keyed by layoutKey since StrictTags changes how tags are parsed
+var layoutCache sync.Map
+
+// resetLayoutCache forgets every cached layout so tags are parsed again,
+// which RegisterTagPreset needs when it changes a preset already in use
+func resetLayoutCache() {
+	layoutCache.Range(func(key, _ interface{}) bool {
+		layoutCache.Delete(key)
+		return true
+	})
+}
+
+// structLayout returns the fields of t with their parsed conf tags, leaving
+// out fields tagged conf:"-". Tags are only parsed the first time a type is
+// seen, a type whose tags fail to parse is not cached.
+func structLayout(t reflect.Type) ([]layoutField, error) {
+	key := layoutKey{typ: t, strict: StrictTags}
+	if cached, ok := layoutCache.Load(key); ok {
+		return cached.([]layoutField), nil
+	}
+
+	layout := make([]layoutField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		ftype := t.Field(i)
+		confTags := ftype.Tag.Get("conf")
+		if confTags == "-" {
+			continue
+		}
+
+		// unexported fields can never be set, a conf tag on one is a mistake
+		if !ftype.IsExported() {
+			if confTags != "" {
+				return nil, failure.Config("unexported field (%s) has a conf tag, export it or use conf:\"-\"", ftype.Name)
+			}
+			continue
+		}
+
+		tag, err := parseTag(confTags, key.strict)
+		if err != nil {
+			return nil, failure.Wrap(err, "parseTag failed (%s)", ftype.Name)
+		}
+
+		if tag.Indexed && !isSliceType(ftype.Type) {
+			return nil, failure.Config("tag (indexed) is only valid on a slice field (%s)", ftype.Name)
+		}
+
+		b, err := parseBounds(tag, ftype.Type)
+		if err != nil {
+			return nil, failure.Wrap(err, "parseBounds failed (%s)", ftype.Name)
+		}
+
+		layout = append(layout, layoutField{index: i, field: ftype, tag: tag, bounds: b})
+	}
+
+	layoutCache.Store(key, layout)
+	return layout, nil
+}
+
+// isSliceType reports whether t, or the type it points to, is a slice
+func isSliceType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Slice
+}
+
+// CheckDuplicateEnvVars reports every env var that more than one field in
+// spec resolves to. Fields does not do this check itself since sharing a
+// variable can be deliberate, call this from a test to opt in.
+func CheckDuplicateEnvVars(spec interface{}, prefix ...string) error {
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	var failed *failure.Multi
+	seen := map[string]Field{}
+	for _, field := range fields {
+		env := field.EnvVariable()
+		if env == "" || env == "-" {
+			continue
+		}
+
+		if first, ok := seen[env]; ok {
+			failed = failure.Append(failed, failure.Config("duplicate env var (%s) used by (%s.%s) and (%s.%s)", env, first.StructName, first.Name, field.StructName, field.Name))
+			continue
+		}
+		seen[env] = field
+	}
+
+	return failed.ErrorOrNil()
+}
+
+// joinPath adds name to the dotted path of a field, PrimaryDB and Host
+// become PrimaryDB.Host
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// joinPrefix nests prefix under parent, so APP and DB become APP_DB
+func joinPrefix(parent, prefix string) string {
+	switch {
+	case prefix == "":
+		return parent
+	case parent == "":
+		return prefix
+	}
+
+	return fmt.Sprintf("%s_%s", parent, prefix)
+}
+
 func NewField(name string, prefix string, sn string, v reflect.Value, t reflect.StructTag, opts Tag) Field {
 	if opts.NoPrefix {
 		prefix = ""
@@ -171,6 +609,7 @@ func NewField(name string, prefix string, sn string, v reflect.Value, t reflect.
 	return Field{
 		StructName:   sn,
 		Name:         name,
+		Path:         name,
 		Prefix:       prefix,
 		EnvVar:       opts.EnvVar,
 		ReflectValue: v,
@@ -180,7 +619,71 @@ func NewField(name string, prefix string, sn string, v reflect.Value, t reflect.
 	}
 }
 
+// validateDefault catches defaults that can never be processed into the
+// field's type. Right now that is only time.Duration, where a missing unit
+// like default:30 would otherwise error or silently become nanoseconds.
+func validateDefault(v reflect.Value, opts Tag) error {
+	if !opts.IsDefault {
+		return nil
+	}
+
+	typ := v.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	// defaults that reference other fields are only known at process time
+	if typ == durationType && !defaultRefPattern.MatchString(opts.Default) {
+		if _, err := time.ParseDuration(opts.Default); err != nil {
+			return failure.ToConfig(err, "default (%s) is not a valid time.Duration", opts.Default)
+		}
+	}
+
+	return nil
+}
+
+// ProcessField converts the string value into the type of the reflected field
+// and assigns it. No tag options are applied, use Fields to get tag aware
+// processing.
 func ProcessField(value string, field reflect.Value) error {
+	return processField(value, field, Field{})
+}
+
+// processItems sets the slice field to items, each processed like an item
+// of a delimited value. It is used for indexed fields so items may contain
+// the delimiter.
+func processItems(items []string, field reflect.Value, f Field) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	sl := reflect.MakeSlice(field.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := processField(item, sl.Index(i), f); err != nil {
+			return failure.Wrap(err, "processField failed at (%d)", i)
+		}
+	}
+	field.Set(sl)
+
+	return nil
+}
+
+func processField(value string, field reflect.Value, f Field) error {
+	value = normalizeValue(value, f)
+	if f.Tag.Regex != "" || f.Tag.Len != "" {
+		if err := checkFormat(value, f); err != nil {
+			return err
+		}
+		// slice items and map pairs are not checked on their own
+		f.Tag.Regex, f.Tag.Len = "", ""
+	}
+	if ok, err := processKnownType(value, field, f); ok {
+		return err
+	}
+
 	typ := field.Type()
 
 	if decoder := DecoderFrom(field); decoder != nil {
@@ -222,6 +725,9 @@ func ProcessField(value string, field reflect.Value) error {
 
 	switch typ.Kind() {
 	case reflect.String:
+		if err := checkOneOf(value, f); err != nil {
+			return err
+		}
 		field.SetString(value)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		var val int64
@@ -229,7 +735,7 @@ func ProcessField(value string, field reflect.Value) error {
 		if value == "" {
 			value = "0"
 		}
-		if field.Kind() == reflect.Int64 && typ.PkgPath() == "time" && typ.Name() == "Duration" {
+		if typ == durationType {
 
 			var d time.Duration
 			d, err = time.ParseDuration(value)
@@ -237,20 +743,55 @@ func ProcessField(value string, field reflect.Value) error {
 				return failure.ToSystem(err, "time.Duration failed, failed to parse int")
 			}
 			val = int64(d)
+			if err = checkBounds(d, f); err != nil {
+				return err
+			}
+		} else if f.Tag.Size {
+			size, err := ParseSize(value)
+			if err != nil {
+				return failure.Wrap(err, "ParseSize failed")
+			}
+			if size > math.MaxInt64 || field.OverflowInt(int64(size)) {
+				return failure.OutOfRange("size (%s) overflows %s", value, typ)
+			}
+			val = int64(size)
+			if err = checkBounds(val, f); err != nil {
+				return err
+			}
 		} else {
-			val, err = strconv.ParseInt(value, 0, typ.Bits())
+			digits, base := intBase(boolInt(value, f), f.Tag.Base)
+			val, err = strconv.ParseInt(digits, base, typ.Bits())
 			if err != nil {
 				return failure.ToSystem(err, "strconv.ParseInt failed")
 			}
+			if err = checkBounds(val, f); err != nil {
+				return err
+			}
 		}
 		field.SetInt(val)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		if value == "" {
 			value = "0"
 		}
-		val, err := strconv.ParseUint(value, 0, typ.Bits())
-		if err != nil {
-			return failure.ToSystem(err, "strconv.ParseUint failed")
+		var val uint64
+		var err error
+		if f.Tag.Size {
+			val, err = ParseSize(value)
+			if err != nil {
+				return failure.Wrap(err, "ParseSize failed")
+			}
+			if field.OverflowUint(val) {
+				return failure.OutOfRange("size (%s) overflows %s", value, typ)
+			}
+		} else {
+			digits, base := intBase(boolInt(value, f), f.Tag.Base)
+			val, err = strconv.ParseUint(digits, base, typ.Bits())
+			if err != nil {
+				return failure.ToSystem(err, "strconv.ParseUint failed")
+			}
+		}
+		if err = checkBounds(val, f); err != nil {
+			return err
 		}
 		field.SetUint(val)
 
@@ -259,7 +800,7 @@ func ProcessField(value string, field reflect.Value) error {
 			value = "false"
 		}
 
-		val, err := strconv.ParseBool(value)
+		val, err := ParseBool(value)
 		if err != nil {
 			return failure.ToSystem(err, "strconv.ParseBool failed")
 		}
@@ -273,16 +814,32 @@ func ProcessField(value string, field reflect.Value) error {
 		if err != nil {
 			return failure.ToSystem(err, "strconv.ParseFloat failed")
 		}
+		if err = checkBounds(val, f); err != nil {
+			return err
+		}
 		field.SetFloat(val)
+	case reflect.Complex64, reflect.Complex128:
+		if value == "" {
+			value = "0"
+		}
+		val, err := strconv.ParseComplex(value, typ.Bits())
+		if err != nil {
+			return failure.ToSystem(err, "strconv.ParseComplex failed")
+		}
+		field.SetComplex(val)
 	case reflect.Slice:
 		sl := reflect.MakeSlice(typ, 0, 0)
 		if typ.Elem().Kind() == reflect.Uint8 {
-			sl = reflect.ValueOf([]byte(value))
+			b, err := decodeBytes(value, f)
+			if err != nil {
+				return err
+			}
+			sl = reflect.ValueOf(b)
 		} else if len(strings.TrimSpace(value)) != 0 {
-			vals := strings.Split(value, ",")
+			vals := strings.Split(value, f.SliceDelimiter())
 			sl = reflect.MakeSlice(typ, len(vals), len(vals))
 			for i, val := range vals {
-				err := ProcessField(val, sl.Index(i))
+				err := processField(val, sl.Index(i), f)
 				if err != nil {
 					return failure.Wrap(err, "processField failed at (%d)", i)
 				}
@@ -290,24 +847,45 @@ func ProcessField(value string, field reflect.Value) error {
 		}
 		field.Set(sl)
 	case reflect.Map:
+		// map[K]struct{} is a set, each item is just a key. map[K]bool may
+		// also list bare keys, which are set to true.
+		isSet := typ.Elem().Kind() == reflect.Struct && typ.Elem().NumField() == 0
+		isBool := typ.Elem().Kind() == reflect.Bool
+
 		mp := reflect.MakeMap(typ)
 		if len(strings.TrimSpace(value)) != 0 {
-			pairs := strings.Split(value, ",")
+			pairs := strings.Split(value, f.MapPairSeparator())
 			for _, pair := range pairs {
-				kvpair := strings.Split(pair, ":")
-				if len(kvpair) != 2 {
+				kvpair := strings.Split(pair, f.MapKVSeparator())
+				switch {
+				case isSet && len(kvpair) != 1:
+					return failure.System("invalid set item: (item: %q)", pair)
+				case !isSet && !(isBool && len(kvpair) == 1) && len(kvpair) != 2:
 					return failure.System("invalid map item: (pair: %q)", pair)
 				}
 
+				// bounds and oneof are for the values, the keys of a set are
+				// its values
+				kf := f
+				if !isSet {
+					kf.bounds = bounds{}
+					kf.Tag.OneOf, kf.Tag.OneOfCI = nil, false
+				}
 				k := reflect.New(typ.Key()).Elem()
-				err := ProcessField(kvpair[0], k)
+				err := processField(kvpair[0], k, kf)
 				if err != nil {
 					return failure.Wrap(err, "processField failed for key (pair: %q) ", pair)
 				}
 				v := reflect.New(typ.Elem()).Elem()
-				err = ProcessField(kvpair[1], v)
-				if err != nil {
-					return failure.Wrap(err, "processField failed for value (pair: %q)", pair)
+				switch {
+				case isSet:
+				case len(kvpair) == 1:
+					v.SetBool(true)
+				default:
+					err = processField(kvpair[1], v, f)
+					if err != nil {
+						return failure.Wrap(err, "processField failed for value (pair: %q)", pair)
+					}
 				}
 				mp.SetMapIndex(k, v)
 			}
@@ -318,6 +896,397 @@ func ProcessField(value string, field reflect.Value) error {
 	return nil
 }
 
+// isValueType reports whether the struct is populated as a single value
+// through one of the supported interfaces rather than field by field
+func isValueType(f reflect.Value) bool {
+	return isKnownType(f.Type()) ||
+		DecoderFrom(f) != nil ||
+		SetterFrom(f) != nil ||
+		TextUnmarshaler(f) != nil ||
+		BinaryUnmarshaler(f) != nil
+}
+
+// processKnownType handles standard library types that either don't
+// implement one of the supported interfaces or need better error messages
+// than their unmarshalers give. It reports false when the field is not one
+// of those types.
+func processKnownType(value string, field reflect.Value, f Field) (bool, error) {
+	typ := field.Type()
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+
+	if !isKnownType(typ) {
+		return false, nil
+	}
+
+	result := reflect.Zero(typ)
+	value = strings.TrimSpace(value)
+	if value != "" {
+		switch typ {
+		case ipType:
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return true, failure.Config("net.ParseIP failed, invalid IP address (%s)", value)
+			}
+			result = reflect.ValueOf(ip)
+		case ipNetType:
+			_, ipNet, err := net.ParseCIDR(value)
+			if err != nil {
+				return true, failure.ToConfig(err, "net.ParseCIDR failed (%s)", value)
+			}
+			result = reflect.ValueOf(ipNet).Elem()
+		case urlType:
+			u, err := url.Parse(value)
+			if err != nil {
+				return true, failure.ToConfig(err, "url.Parse failed (%s)", value)
+			}
+			result = reflect.ValueOf(u).Elem()
+		}
+	}
+
+	if isPtr {
+		ptr := reflect.New(typ)
+		ptr.Elem().Set(result)
+		result = ptr
+	}
+	field.Set(result)
+
+	return true, nil
+}
+
+func isKnownType(typ reflect.Type) bool {
+	switch typ {
+	case ipType, ipNetType, urlType:
+		return true
+	}
+
+	return false
+}
+
+// sizeUnits maps the size suffixes ParseSize accepts, in lower case, to
+// their multiplier. KB, MB, GB and TB are binary like KiB, MiB, GiB and TiB,
+// the single letters K, M, G and T are decimal.
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"m":   1e6,
+	"g":   1e9,
+	"t":   1e12,
+	"kb":  1 << 10,
+	"mb":  1 << 20,
+	"gb":  1 << 30,
+	"tb":  1 << 40,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a humanized byte size like 10MB, 1.5GiB or 1_000_000 into
+// a number of bytes, see sizeUnits for the suffixes. Underscores may be used
+// to separate digits and the result must be a whole number of bytes.
+func ParseSize(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == '_')
+	})
+	if end < 0 {
+		end = len(value)
+	}
+
+	number := strings.ReplaceAll(value[:end], "_", "")
+	unit := strings.ToLower(strings.TrimSpace(value[end:]))
+	multiplier, ok := sizeUnits[unit]
+	if !ok || number == "" {
+		return 0, failure.Config("invalid size (%s)", value)
+	}
+
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return 0, failure.ToConfig(err, "strconv.ParseUint failed (%s)", value)
+		}
+		if n > math.MaxUint64/multiplier {
+			return 0, failure.OutOfRange("size (%s) overflows uint64", value)
+		}
+		return n * multiplier, nil
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, failure.ToConfig(err, "strconv.ParseFloat failed (%s)", value)
+	}
+
+	size := n * float64(multiplier)
+	if size >= math.MaxUint64 {
+		return 0, failure.OutOfRange("size (%s) overflows uint64", value)
+	}
+	if size != math.Trunc(size) {
+		return 0, failure.Config("size (%s) is not a whole number of bytes", value)
+	}
+
+	return uint64(size), nil
+}
+
+// ParseBool extends strconv.ParseBool with the words ops teams tend to use
+// in env vars. yes, y, on, enable and enabled are true, no, n, off, disable
+// and disabled are false, all case-insensitive. Anything else is handed to
+// strconv.ParseBool.
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "on", "enable", "enabled":
+		return true, nil
+	case "no", "n", "off", "disable", "disabled":
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// normalizeValue applies the trim and unquote tags. Whitespace is trimmed
+// both before and after unquoting so " \" foo \" " becomes foo when both are
+// used.
+func normalizeValue(value string, f Field) string {
+	if f.Tag.Trim {
+		value = strings.TrimSpace(value)
+	}
+
+	if f.Tag.Unquote && len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			value = value[1 : len(value)-1]
+		}
+
+		if f.Tag.Trim {
+			value = strings.TrimSpace(value)
+		}
+	}
+
+	return value
+}
+
+// expandValue applies the expand tag, replacing $VAR and ${VAR} with the
+// value lookup finds for VAR. Unset vars become empty unless the tag is
+// expand-strict, which fails and names every one of them.
+func expandValue(value string, f Field, lookup func(key string) (string, bool)) (string, error) {
+	var missing []string
+	value = os.Expand(value, func(key string) string {
+		v, ok := lookup(key)
+		if !ok {
+			missing = append(missing, key)
+		}
+		return v
+	})
+
+	if f.Tag.ExpandStrict && len(missing) > 0 {
+		return "", failure.Config("expand failed, unset env vars (%s)", strings.Join(missing, ","))
+	}
+
+	return value, nil
+}
+
+// decodeBytes applies the encoding tag to a []byte value, without one the
+// value is used as is
+func decodeBytes(value string, f Field) ([]byte, error) {
+	switch f.Tag.Encoding {
+	case EncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, failure.ToConfig(err, "base64 decode failed for (%s)", f.Name)
+		}
+		return b, nil
+	case EncodingHex:
+		b, err := hex.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, failure.ToConfig(err, "hex decode failed for (%s)", f.Name)
+		}
+		return b, nil
+	}
+
+	return []byte(value), nil
+}
+
+// checkOneOf enforces the oneof tag, comparing case-insensitively when the
+// oneof-ci tag is also used
+func checkOneOf(value string, f Field) error {
+	if len(f.Tag.OneOf) == 0 {
+		return nil
+	}
+
+	for _, allowed := range f.Tag.OneOf {
+		if value == allowed || (f.Tag.OneOfCI && strings.EqualFold(value, allowed)) {
+			return nil
+		}
+	}
+
+	return failure.Validation("value %q not in allowed set for (%s)", value, f.Name)
+}
+
+// regexCache holds the compiled pattern of every regex tag by pattern
+var regexCache sync.Map
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := regexCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// parseLen parses the len tag, N is an exact length, N- a minimum and N-M a
+// range. max is -1 when there is no maximum.
+func parseLen(value string) (min, max int, err error) {
+	lower, upper, isRange := strings.Cut(value, "-")
+	if min, err = strconv.Atoi(lower); err != nil || min < 0 {
+		return 0, 0, failure.Config("length (%s) is not a non negative int", lower)
+	}
+
+	switch {
+	case !isRange:
+		return min, min, nil
+	case upper == "":
+		return min, -1, nil
+	}
+
+	if max, err = strconv.Atoi(upper); err != nil || max < min {
+		return 0, 0, failure.Config("max length (%s) is not an int of at least %d", upper, min)
+	}
+
+	return min, max, nil
+}
+
+// checkFormat enforces the regex and len tags. These are meant for secrets,
+// so the failure names the field and the constraint but never the value.
+func checkFormat(value string, f Field) error {
+	if f.Tag.Regex != "" {
+		re, err := compileRegex(f.Tag.Regex)
+		if err != nil {
+			return failure.ToConfig(err, "regex (%s) is invalid for (%s)", f.Tag.Regex, f.Name)
+		}
+		if !re.MatchString(value) {
+			return failure.Validation("value for (%s) does not match regex (%s)", f.Name, f.Tag.Regex)
+		}
+	}
+
+	if f.Tag.Len != "" {
+		min, max, err := parseLen(f.Tag.Len)
+		if err != nil {
+			return failure.Wrap(err, "len (%s) is invalid for (%s)", f.Tag.Len, f.Name)
+		}
+		if n := utf8.RuneCountInString(value); n < min || (max >= 0 && n > max) {
+			return failure.Validation("value for (%s) does not have len (%s)", f.Name, f.Tag.Len)
+		}
+	}
+
+	return nil
+}
+
+// bounds are the min and max tags of a field parsed into the type its
+// values are checked as, int64, uint64, float64 or time.Duration. A bound
+// that is not set is nil and means there is no limit on that side.
+type bounds struct {
+	min interface{}
+	max interface{}
+}
+
+// parseBounds parses the min and max tags for t, the type of the field. A
+// slice or map is bounded by its items, a set by its keys.
+func parseBounds(tag Tag, t reflect.Type) (bounds, error) {
+	var b bounds
+	if tag.Min == "" && tag.Max == "" {
+		return b, nil
+	}
+
+	t = boundsType(t)
+	var err error
+	if b.min, err = parseBound("min", tag.Min, t); err != nil {
+		return b, err
+	}
+	if b.max, err = parseBound("max", tag.Max, t); err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+// boundsType is the type the bounds of a field of type t apply to
+func boundsType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return boundsType(t.Elem())
+		}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0 {
+			return boundsType(t.Key())
+		}
+		return boundsType(t.Elem())
+	}
+
+	return t
+}
+
+func parseBound(key, value string, t reflect.Type) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, failure.ToConfig(err, "%s (%s) is not a valid time.Duration", key, value)
+		}
+		return d, nil
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, failure.ToConfig(err, "%s (%s) is not a valid int", key, value)
+		}
+		return i, nil
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return nil, failure.ToConfig(err, "%s (%s) is not a valid uint", key, value)
+		}
+		return u, nil
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, failure.ToConfig(err, "%s (%s) is not a valid float", key, value)
+		}
+		return f, nil
+	}
+
+	return nil, failure.Config("tag (%s) is only valid on a number or time.Duration field, not %s", key, t)
+}
+
+// checkBounds enforces the min and max tags, bounds parsed for another type
+// never match T and are not checked
+func checkBounds[T int64 | uint64 | float64 | time.Duration](val T, f Field) error {
+	if min, ok := f.bounds.min.(T); ok && val < min {
+		return failure.OutOfRange("value %v is below min %s for (%s)", val, f.Tag.Min, f.Name)
+	}
+	if max, ok := f.bounds.max.(T); ok && val > max {
+		return failure.OutOfRange("value %v exceeds max %s for (%s)", val, f.Tag.Max, f.Name)
+	}
+
+	return nil
+}
+
 // Decoder has the same semantics as Setter, but takes higher precedence.
 // It is provided for historical compatibility.
 type Decoder interface {
@@ -358,7 +1327,61 @@ func TextUnmarshaler(field reflect.Value) (t encoding.TextUnmarshaler) {
 	return t
 }
 
+func TextMarshaler(field reflect.Value) (t encoding.TextMarshaler) {
+	interfaceFrom(field, func(v interface{}, ok *bool) { t, *ok = v.(encoding.TextMarshaler) })
+	return t
+}
+
 func BinaryUnmarshaler(field reflect.Value) (b encoding.BinaryUnmarshaler) {
 	interfaceFrom(field, func(v interface{}, ok *bool) { b, *ok = v.(encoding.BinaryUnmarshaler) })
 	return b
 }
+
+// boolInt applies the bool-int tag, true becomes 1 and false becomes 0 with
+// case ignored. Any other value, including 1 and 0, is returned as is and
+// parsed as an integer.
+func boolInt(value string, f Field) string {
+	if !f.Tag.BoolInt {
+		return value
+	}
+
+	switch {
+	case strings.EqualFold(value, "true"):
+		return "1"
+	case strings.EqualFold(value, "false"):
+		return "0"
+	}
+
+	return value
+}
+
+// intBase returns value and the base to parse it with. Without a base tag
+// the base is 0, which lets strconv detect it from a 0x, 0o or 0b prefix and
+// otherwise treats the value as decimal. A base tag forces that base, a
+// prefix that matches it is still accepted.
+func intBase(value string, base int) (string, int) {
+	if base == 0 {
+		return value, 0
+	}
+
+	var sign string
+	if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "+") {
+		sign, value = value[:1], value[1:]
+	}
+
+	var prefix string
+	switch base {
+	case 2:
+		prefix = "0b"
+	case 8:
+		prefix = "0o"
+	case 16:
+		prefix = "0x"
+	}
+
+	if prefix != "" && len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+		value = value[len(prefix):]
+	}
+
+	return sign + value, base
+}
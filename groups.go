@@ -0,0 +1,71 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// FlagGroupAnnotation is the pflag annotation BindCLI uses to record the
+// group tag of a flag
+const FlagGroupAnnotation = "conf_flag_group"
+
+var registerGroupFunc sync.Once
+
+// BindCLIWithGroups is BindCLI with a usage template that lists flags under
+// a heading for their group tag, like "Database Flags:". Flags without a
+// group are listed first under the usual "Flags:" heading.
+func BindCLIWithGroups(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...string) error {
+	if err := BindCLI(cmd, v, spec, prefix...); err != nil {
+		return failure.Wrap(err, "BindCLI failed")
+	}
+
+	registerGroupFunc.Do(func() {
+		cobra.AddTemplateFunc("groupedFlagUsages", GroupedFlagUsages)
+	})
+
+	tmpl := cmd.UsageTemplate()
+	tmpl = strings.ReplaceAll(tmpl, "{{.LocalFlags.FlagUsages", "{{groupedFlagUsages .LocalFlags")
+	tmpl = strings.ReplaceAll(tmpl, "{{.InheritedFlags.FlagUsages", "{{groupedFlagUsages .InheritedFlags")
+	cmd.SetUsageTemplate(tmpl)
+
+	return nil
+}
+
+// GroupedFlagUsages is like FlagSet.FlagUsages but flags annotated with
+// FlagGroupAnnotation follow the others under a heading per group, in the
+// order the groups are first seen.
+func GroupedFlagUsages(fs *pflag.FlagSet) string {
+	ungrouped := pflag.NewFlagSet("ungrouped", pflag.ContinueOnError)
+	groups := map[string]*pflag.FlagSet{}
+	var order []string
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		group := f.Annotations[FlagGroupAnnotation]
+		if len(group) == 0 {
+			ungrouped.AddFlag(f)
+			return
+		}
+
+		set, ok := groups[group[0]]
+		if !ok {
+			set = pflag.NewFlagSet(group[0], pflag.ContinueOnError)
+			groups[group[0]] = set
+			order = append(order, group[0])
+		}
+		set.AddFlag(f)
+	})
+
+	var b strings.Builder
+	b.WriteString(ungrouped.FlagUsages())
+	for _, name := range order {
+		fmt.Fprintf(&b, "\n%s Flags:\n%s", name, groups[name].FlagUsages())
+	}
+
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package conf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type GroupedCLIConfig struct {
+	Verbose bool   `conf:"env:APP_VERBOSE,cli:verbose,cli-u:verbose output"`
+	DBHost  string `conf:"env:APP_DB_HOST,cli:db-host,cli-u:database host,group:Database"`
+	DBPort  string `conf:"env:APP_DB_PORT,cli:db-port,cli-u:database port,group:Database"`
+	Region  string `conf:"env:APP_REGION,cli:region,cli-u:aws region,group:AWS"`
+}
+
+func TestBindCLIWithGroups_Success(t *testing.T) {
+	cmd := &cobra.Command{
+		Use: "app",
+		Run: func(cmd *cobra.Command, args []string) {},
+	}
+
+	var config GroupedCLIConfig
+	err := conf.BindCLIWithGroups(cmd, viper.New(), &config)
+	require.NoError(t, err)
+
+	f := cmd.Flags().Lookup("db-host")
+	require.NotNil(t, f)
+	assert.Equal(t, []string{"Database"}, f.Annotations[conf.FlagGroupAnnotation])
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	require.NoError(t, cmd.Usage())
+
+	usage := out.String()
+	verbose := strings.Index(usage, "--verbose")
+	database := strings.Index(usage, "Database Flags:")
+	aws := strings.Index(usage, "AWS Flags:")
+
+	require.True(t, verbose >= 0, usage)
+	require.True(t, database > verbose, usage)
+	require.True(t, aws > database, usage)
+	assert.True(t, strings.Index(usage, "--db-host") > database)
+	assert.True(t, strings.Index(usage, "--region") > aws)
+}
+
+func TestGroupedFlagUsages_NoGroups(t *testing.T) {
+	cmd := &cobra.Command{Use: "app"}
+	cmd.Flags().String("name", "", "a name")
+
+	assert.Equal(t, cmd.Flags().FlagUsages(), conf.GroupedFlagUsages(cmd.Flags()))
+}
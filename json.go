@@ -0,0 +1,36 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/rsb/failure"
+)
+
+// ProcessJSON decodes a JSON object from r and populates spec from it. Each
+// field is looked up by its json tag key, falling back to its env name, and
+// the value is run through the same processing as the env path, so defaults,
+// required checks and type coercion all behave like ProcessEnv.
+func ProcessJSON(r io.Reader, spec interface{}) error {
+	var data map[string]interface{}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return failure.ToConfig(err, "json.Decode failed")
+	}
+
+	if err := process(context.Background(), spec, []Source{JSONSource(data)}, defaultOptions()); err != nil {
+		return failure.Wrap(err, "process failed")
+	}
+
+	return nil
+}
+
+// JSONSource resolves fields from an already decoded JSON object, using
+// Field.JSONKey as the key. Nested objects are matched to nested structs like
+// TOMLSource does.
+func JSONSource(data map[string]interface{}) Source {
+	return MapSource(data, Field.JSONKey)
+}
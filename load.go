@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// LoadEnv allocates a T, populates it with ProcessEnv and returns it, so the
+// spec does not have to be declared first:
+//
+//	config, err := conf.LoadEnv[MyConfig]("APP")
+//
+// T must be a struct type. Nil is returned when processing fails.
+func LoadEnv[T any](prefix ...string) (*T, error) {
+	spec := new(T)
+	if err := ProcessEnv(spec, prefix...); err != nil {
+		return nil, failure.Wrap(err, "ProcessEnv failed")
+	}
+
+	return spec, nil
+}
+
+// LoadCLI is LoadEnv built on ProcessCLI. The flags must already be bound,
+// which BindCLI can do with a zero T since it only needs the type.
+func LoadCLI[T any](cmd *cobra.Command, v *viper.Viper, prefix ...string) (*T, error) {
+	spec := new(T)
+	if err := ProcessCLI(cmd, v, spec, prefix...); err != nil {
+		return nil, failure.Wrap(err, "ProcessCLI failed")
+	}
+
+	return spec, nil
+}
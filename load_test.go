@@ -0,0 +1,57 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEnv(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,required"`
+		Port int    `conf:"env:PORT,default:8080"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_HOST", "localhost")
+
+	config, err := conf.LoadEnv[MyConfig]("APP")
+	require.NoError(t, err, "conf.LoadEnv is not expected to fail")
+	assert.Equal(t, &MyConfig{Host: "localhost", Port: 8080}, config)
+
+	os.Clearenv()
+	config, err = conf.LoadEnv[MyConfig]("APP")
+	require.Error(t, err, "conf.LoadEnv is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Host,APP_HOST) missing value")
+	assert.Nil(t, config)
+
+	_, err = conf.LoadEnv[int]()
+	require.Error(t, err, "conf.LoadEnv is expected to fail for a non struct")
+	assert.ErrorIs(t, err, conf.InvalidSpecFailure)
+}
+
+func TestLoadCLI(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,cli:host"`
+		Port int    `conf:"env:PORT,cli:port,default:8080"`
+	}
+
+	os.Clearenv()
+	setenv(t, "PORT", "9090")
+
+	cmd := &cobra.Command{Use: "my-cmd", Run: func(cmd *cobra.Command, args []string) {}}
+	v := viper.New()
+	require.NoError(t, conf.BindCLI(cmd, v, &MyConfig{}))
+	cmd.SetArgs([]string{"--host", "db.internal"})
+	require.NoError(t, cmd.Execute())
+
+	config, err := conf.LoadCLI[MyConfig](cmd, v)
+	require.NoError(t, err, "conf.LoadCLI is not expected to fail")
+	assert.Equal(t, &MyConfig{Host: "db.internal", Port: 9090}, config)
+	os.Clearenv()
+}
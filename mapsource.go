@@ -0,0 +1,117 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MapSource resolves fields from decoded data, like a JSON object or a TOML
+// document, using key to name the entry of each field. A field with the path
+// PrimaryDB.Host is looked up in the nested map primarydb, matched without
+// regard to case. Fields keyed by their env name are also looked up at the
+// top level, so flat data keyed by env name works as well. Lists and maps
+// are turned back into the string form ProcessEnv reads.
+func MapSource(data map[string]interface{}, key func(Field) string) Source {
+	return SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		name := key(field)
+		if name == "" || name == "-" {
+			return "", false, nil
+		}
+
+		item, ok := lookupTable(data, field, name)
+		if !ok {
+			return "", false, nil
+		}
+
+		return stringifyValue(item, field), true, nil
+	})
+}
+
+// lookupTable finds key in the table for the structs that contain field,
+// falling back to the top level of data when key is the env name of the
+// field. A key from a toml or yaml tag only names an entry of its own table
+// so it never matches an unrelated top level entry.
+func lookupTable(data map[string]interface{}, field Field, key string) (interface{}, bool) {
+	segments := strings.Split(field.Path, ".")
+	if len(segments) == 1 {
+		item, ok := data[key]
+		return item, ok
+	}
+
+	table := data
+	nested := true
+	for _, name := range segments[:len(segments)-1] {
+		next, ok := tableEntry(table, name).(map[string]interface{})
+		if !ok {
+			nested = false
+			break
+		}
+		table = next
+	}
+
+	if nested {
+		if item, ok := table[key]; ok {
+			return item, true
+		}
+	}
+
+	if key != field.EnvVariable() {
+		return nil, false
+	}
+
+	item, ok := data[key]
+	return item, ok
+}
+
+// tableEntry is the entry of table named name, matched without regard to
+// case since table names are usually lower case and field names are not
+func tableEntry(table map[string]interface{}, name string) interface{} {
+	if item, ok := table[name]; ok {
+		return item
+	}
+
+	for k, item := range table {
+		if strings.EqualFold(k, name) {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// stringifyValue turns a decoded value back into the string form that
+// processField expects. Lists are joined with the field's slice delimiter
+// and objects become key/value pairs using the field's map separators.
+func stringifyValue(data interface{}, f Field) string {
+	switch d := data.(type) {
+	case nil:
+		return ""
+	case string:
+		return d
+	case json.Number:
+		return d.String()
+	case []interface{}:
+		items := make([]string, 0, len(d))
+		for _, item := range d {
+			items = append(items, stringifyValue(item, f))
+		}
+		return strings.Join(items, f.SliceDelimiter())
+	case map[string]interface{}:
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(d))
+		for _, k := range keys {
+			pairs = append(pairs, k+f.MapKVSeparator()+stringifyValue(d[k], f))
+		}
+		return strings.Join(pairs, f.MapPairSeparator())
+	default:
+		return fmt.Sprintf("%v", d)
+	}
+}
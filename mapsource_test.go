@@ -0,0 +1,128 @@
+package conf_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessFormats(t *testing.T) {
+	type Database struct {
+		Host string `conf:"env:DB_HOST,json:host,toml:host,yaml:host,required"`
+		Port int    `conf:"env:DB_PORT,json:port,toml:port,yaml:port,default:5432"`
+	}
+
+	type MyConfig struct {
+		Name    string            `conf:"env:APP_NAME,json:name,toml:name,yaml:name"`
+		Timeout time.Duration     `conf:"env:TIMEOUT,default:5s"`
+		Debug   bool              `conf:"env:DEBUG"`
+		IDs     []string          `conf:"env:IDS"`
+		Ports   []int             `conf:"env:PORTS,json:ports,toml:ports,yaml:ports"`
+		Codes   map[string]string `conf:"env:CODES"`
+		Ratio   float64           `conf:"env:RATIO"`
+		Big     int64             `conf:"env:BIG"`
+		DB      Database
+	}
+
+	tests := []struct {
+		name      string
+		process   func(r io.Reader, spec interface{}) error
+		input     string
+		empty     string
+		invalid   string
+		decodeErr string
+	}{
+		{
+			name:    "json",
+			process: conf.ProcessJSON,
+			input: `{
+				"name": "my-app",
+				"DEBUG": true,
+				"IDS": ["id1", "id2"],
+				"ports": [80, 443],
+				"CODES": {"codeA": "A", "codeB": "B"},
+				"RATIO": 0.25,
+				"BIG": 9007199254740993,
+				"db": {"host": "localhost"}
+			}`,
+			empty:     `{}`,
+			invalid:   `{"DB_HOST":`,
+			decodeErr: "json.Decode failed",
+		},
+		{
+			name:    "toml",
+			process: conf.ProcessTOML,
+			input: `
+name = "my-app"
+DEBUG = true
+IDS = ["id1", "id2"]
+ports = [80, 443]
+CODES = { codeA = "A", codeB = "B" }
+RATIO = 0.25
+BIG = 9007199254740993
+
+[db]
+host = "localhost"
+`,
+			empty:     `OTHER = 1`,
+			invalid:   `DB_HOST = `,
+			decodeErr: "toml.LoadReader failed",
+		},
+		{
+			name:    "yaml",
+			process: conf.ProcessYAML,
+			input: `
+name: my-app
+DEBUG: true
+IDS: [id1, id2]
+ports:
+  - 80
+  - 443
+CODES:
+  codeA: A
+  codeB: B
+RATIO: 0.25
+BIG: 9007199254740993
+db:
+  host: localhost
+`,
+			empty:     "",
+			invalid:   "- a\n- b\n",
+			decodeErr: "yaml.Decode failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config MyConfig
+			err := tt.process(strings.NewReader(tt.input), &config)
+			require.NoError(t, err, "process is not expected to fail")
+
+			expected := MyConfig{
+				Name:    "my-app",
+				Timeout: 5 * time.Second,
+				Debug:   true,
+				IDs:     []string{"id1", "id2"},
+				Ports:   []int{80, 443},
+				Codes:   map[string]string{"codeA": "A", "codeB": "B"},
+				Ratio:   0.25,
+				Big:     9007199254740993,
+				DB:      Database{Host: "localhost", Port: 5432},
+			}
+			assert.Equal(t, expected, config)
+
+			err = tt.process(strings.NewReader(tt.empty), &MyConfig{})
+			require.Error(t, err, "process is expected to fail")
+			assert.Contains(t, err.Error(), "required key (DB.Host,DB_HOST) missing value")
+
+			err = tt.process(strings.NewReader(tt.invalid), &MyConfig{})
+			require.Error(t, err, "process is expected to fail")
+			assert.Contains(t, err.Error(), tt.decodeErr)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package conf
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// Merge layers sources on top of each other and processes spec once from the
+// result. Each source returns values keyed by full env name, like ProcessMap
+// takes, and later sources override earlier ones. Defaults and required
+// checks are applied to the merged values, so a required field only has to
+// be set by one of the sources. A file then env layering looks like:
+//
+//	conf.Merge(&config, loadFile, conf.Environ)
+func Merge(spec interface{}, sources ...func() (map[string]string, error)) error {
+	merged := map[string]string{}
+	for i, src := range sources {
+		values, err := src()
+		if err != nil {
+			return failure.Wrap(err, "source (%d) failed", i)
+		}
+
+		for k, v := range values {
+			merged[k] = v
+		}
+	}
+
+	return ProcessMap(merged, spec)
+}
+
+// Environ returns the process environment as a map, for use with Merge
+func Environ() (map[string]string, error) {
+	result := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		result[k] = v
+	}
+
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package conf_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MergeConfig struct {
+	Host  string `conf:"env:MERGE_HOST,required"`
+	Port  int    `conf:"env:MERGE_PORT,default:80"`
+	Token string `conf:"env:MERGE_TOKEN,required"`
+}
+
+func layer(values map[string]string) func() (map[string]string, error) {
+	return func() (map[string]string, error) {
+		return values, nil
+	}
+}
+
+func TestMerge_LaterSourcesWin(t *testing.T) {
+	file := layer(map[string]string{"MERGE_HOST": "file-host", "MERGE_PORT": "8080"})
+	env := layer(map[string]string{"MERGE_PORT": "9090", "MERGE_TOKEN": "secret"})
+
+	var config MergeConfig
+	err := conf.Merge(&config, file, env)
+	require.NoError(t, err)
+
+	expected := MergeConfig{Host: "file-host", Port: 9090, Token: "secret"}
+	assert.Equal(t, expected, config)
+}
+
+func TestMerge_RequiredCheckedOnMergedValues(t *testing.T) {
+	file := layer(map[string]string{"MERGE_HOST": "file-host"})
+	env := layer(map[string]string{})
+
+	var config MergeConfig
+	err := conf.Merge(&config, file, env)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key (Token,MERGE_TOKEN) missing value")
+	assert.NotContains(t, err.Error(), "MERGE_HOST")
+}
+
+func TestMerge_SourceFailure(t *testing.T) {
+	failing := func() (map[string]string, error) {
+		return nil, errors.New("file not found")
+	}
+
+	var config MergeConfig
+	err := conf.Merge(&config, layer(nil), failing)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "source (1) failed")
+}
+
+func TestMerge_Environ(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "MERGE_TOKEN", "from-env")
+	setenv(t, "MERGE_HOST", "env-host")
+
+	file := layer(map[string]string{"MERGE_HOST": "file-host", "MERGE_TOKEN": "file-token", "MERGE_PORT": "8080"})
+
+	var config MergeConfig
+	err := conf.Merge(&config, file, conf.Environ)
+	require.NoError(t, err)
+
+	expected := MergeConfig{Host: "env-host", Port: 8080, Token: "from-env"}
+	assert.Equal(t, expected, config)
+	os.Clearenv()
+}
@@ -0,0 +1,25 @@
+package conf
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// MustProcessEnv is ProcessEnv for use in main, it panics when processing
+// fails. The panic message has the full failure, so every missing or invalid
+// variable is listed and not just the first.
+func MustProcessEnv(spec interface{}, prefix ...string) {
+	if err := ProcessEnv(spec, prefix...); err != nil {
+		panic(fmt.Sprintf("conf: ProcessEnv failed: %s", err))
+	}
+}
+
+// MustProcessCLI is ProcessCLI for use in main, it panics the same way
+// MustProcessEnv does.
+func MustProcessCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...string) {
+	if err := ProcessCLI(cmd, v, spec, prefix...); err != nil {
+		panic(fmt.Sprintf("conf: ProcessCLI failed: %s", err))
+	}
+}
@@ -0,0 +1,62 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMustProcessEnv(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,required"`
+		Port int    `conf:"env:PORT,required"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	defer func() {
+		r := recover()
+		require.NotNil(t, r, "conf.MustProcessEnv is expected to panic")
+		msg, ok := r.(string)
+		require.True(t, ok)
+		assert.Contains(t, msg, "conf: ProcessEnv failed")
+		assert.Contains(t, msg, "required key (Host,HOST) missing value")
+		assert.Contains(t, msg, "required key (Port,PORT) missing value")
+	}()
+
+	conf.MustProcessEnv(&config)
+}
+
+func TestMustProcessEnv_Success(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,default:localhost"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	assert.NotPanics(t, func() { conf.MustProcessEnv(&config) })
+	assert.Equal(t, "localhost", config.Host)
+}
+
+func TestMustProcessCLI(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST,cli:host,required"`
+	}
+
+	os.Clearenv()
+	var config MyConfig
+	cmd := &cobra.Command{Use: "my-cmd"}
+	defer func() {
+		r := recover()
+		require.NotNil(t, r, "conf.MustProcessCLI is expected to panic")
+		assert.Contains(t, r, "conf: ProcessCLI failed")
+		assert.Contains(t, r, "required key (field:Host,env:HOST,cli:host) missing value")
+	}()
+
+	conf.MustProcessCLI(cmd, viper.New(), &config)
+}
@@ -0,0 +1,215 @@
+package conf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameCase controls how an env name is derived from the field name for
+// fields that have no env tag. The zero value derives nothing, which leaves
+// those fields without an env var just like before.
+type NameCase int
+
+const (
+	NameCaseNone NameCase = iota
+	// NameCaseUpperSnake turns DBHost into DB_HOST
+	NameCaseUpperSnake
+	// NameCaseLowerSnake turns DBHost into db_host
+	NameCaseLowerSnake
+	// NameCaseKebab turns DBHost into db-host
+	NameCaseKebab
+)
+
+// EnvName derives the env name for a field called name
+func (n NameCase) EnvName(name string) string {
+	return n.envName(name, nil)
+}
+
+// envName is EnvName keeping the mixed case words in camelWords whole, see
+// Config.CamelWords
+func (n NameCase) envName(name string, camelWords []string) string {
+	if n == NameCaseNone {
+		return ""
+	}
+
+	words := CamelSplitWords(name, camelWords...)
+	switch n {
+	case NameCaseUpperSnake:
+		return strings.ToUpper(strings.Join(words, "_"))
+	case NameCaseLowerSnake:
+		return strings.ToLower(strings.Join(words, "_"))
+	case NameCaseKebab:
+		return strings.ToLower(strings.Join(words, "-"))
+	}
+
+	return ""
+}
+
+type runeClass int
+
+const (
+	classOther runeClass = iota
+	classUpper
+	classLower
+	classDigit
+	classWord
+)
+
+type run struct {
+	text  string
+	class runeClass
+}
+
+// CamelSplit splits a Go identifier into the words used to derive env names.
+// The rules, applied left to right, are:
+//
+//   - a lower case letter followed by an upper case one ends a word: fooBar
+//     is foo, Bar
+//   - a run of upper case letters is an acronym, when lower case letters
+//     follow it the last upper case letter starts the next word: HTTPSProxy
+//     is HTTPS, Proxy
+//   - an acronym followed by a single s that ends the run is a plural: IDs
+//     and UserIDs keep IDs whole
+//   - an acronym followed by a single lower case letter and a number is a
+//     version: UUIDv4 is UUID, v4
+//   - digits stay with the word before them, S3Bucket is S3, Bucket and
+//     HTTP2Server is HTTP2, Server
+//   - leading digits join the word after them, 2FACode is 2FA, Code
+//   - anything other than a letter or digit, like _, separates words and is
+//     dropped
+//
+// Mixed case words are split at the case change, OAuth is O, Auth. Use
+// CamelSplitWords to keep them whole, or give the field an env tag.
+func CamelSplit(src string) []string {
+	return CamelSplitWords(src)
+}
+
+// CamelSplitWords is CamelSplit that never splits the mixed case words in
+// camelWords, so with OAuth OAuth2Token is OAuth2, Token
+func CamelSplitWords(src string, camelWords ...string) []string {
+	var words []string
+	var cur string
+
+	flush := func() {
+		if cur == "" || isDigits(cur) {
+			// leading digits wait for the word that follows them
+			return
+		}
+		words = append(words, cur)
+		cur = ""
+	}
+
+	runs := camelRuns(src, camelWords)
+	for i := 0; i < len(runs); i++ {
+		r := runs[i]
+		switch r.class {
+		case classOther:
+			flush()
+		case classDigit, classLower:
+			cur += r.text
+		case classWord:
+			flush()
+			cur += r.text
+		case classUpper:
+			flush()
+			if i+1 >= len(runs) || runs[i+1].class != classLower {
+				cur += r.text
+				continue
+			}
+
+			upper, lower := r.text, runs[i+1].text
+			after := classOther
+			if i+2 < len(runs) {
+				after = runs[i+2].class
+			}
+
+			switch {
+			case len(upper) > 1 && lower == "s" && after != classLower:
+				cur += upper + lower
+			case len(upper) > 1 && len(lower) == 1 && after == classDigit:
+				cur += upper
+				flush()
+				cur = lower
+			case len(upper) > 1:
+				cur += upper[:len(upper)-1]
+				flush()
+				cur = upper[len(upper)-1:] + lower
+			default:
+				cur += upper + lower
+			}
+			i++
+		}
+	}
+
+	if cur != "" {
+		words = append(words, cur)
+	}
+
+	return words
+}
+
+// camelRuns breaks src into runs of upper case letters, lower case letters,
+// digits, camelWords and everything else
+func camelRuns(src string, camelWords []string) []run {
+	var runs []run
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		if w, ok := camelWordAt(runes[i:], camelWords); ok {
+			runs = append(runs, run{text: w, class: classWord})
+			i += len([]rune(w))
+			continue
+		}
+
+		class := classOf(runes[i])
+		j := i + 1
+		for j < len(runes) && classOf(runes[j]) == class {
+			if _, ok := camelWordAt(runes[j:], camelWords); ok {
+				break
+			}
+			j++
+		}
+		runs = append(runs, run{text: string(runes[i:j]), class: class})
+		i = j
+	}
+
+	return runs
+}
+
+func camelWordAt(runes []rune, camelWords []string) (string, bool) {
+	for _, w := range camelWords {
+		wr := []rune(w)
+		if len(runes) < len(wr) || string(runes[:len(wr)]) != w {
+			continue
+		}
+		// OAuthor is not OAuth followed by or
+		if len(runes) > len(wr) && unicode.IsLower(runes[len(wr)]) {
+			continue
+		}
+		return w, true
+	}
+
+	return "", false
+}
+
+func classOf(r rune) runeClass {
+	switch {
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsDigit(r):
+		return classDigit
+	}
+
+	return classOther
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return true
+}
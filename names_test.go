@@ -0,0 +1,138 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameCase_EnvName(t *testing.T) {
+	tests := []struct {
+		nameCase conf.NameCase
+		expected string
+	}{
+		{nameCase: conf.NameCaseNone, expected: ""},
+		{nameCase: conf.NameCaseUpperSnake, expected: "CLI_HOST_NAME"},
+		{nameCase: conf.NameCaseLowerSnake, expected: "cli_host_name"},
+		{nameCase: conf.NameCaseKebab, expected: "cli-host-name"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.nameCase.EnvName("CLIHostName"))
+	}
+}
+
+func TestConfig_NameCase(t *testing.T) {
+	type MyConfig struct {
+		DBHost   string
+		Port     int    `conf:"default:8080"`
+		Explicit string `conf:"env:EXPLICIT_VAR"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_db_host", "localhost")
+	setenv(t, "APP_EXPLICIT_VAR", "value")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	c.NameCase = conf.NameCaseLowerSnake
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	assert.Equal(t, "localhost", config.DBHost)
+	assert.Equal(t, 8080, config.Port)
+	assert.Equal(t, "value", config.Explicit)
+
+	names, err := c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_db_host", "APP_port", "APP_EXPLICIT_VAR"}, names)
+
+	c.NameCase = conf.NameCaseUpperSnake
+	names, err = c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HOST", "APP_PORT", "APP_EXPLICIT_VAR"}, names)
+
+	err = conf.ProcessEnv(&MyConfig{})
+	require.Error(t, err, "conf.ProcessEnv is expected to fail without derived names")
+	assert.Contains(t, err.Error(), "env: is required but empty for (DBHost)")
+	os.Clearenv()
+}
+
+func TestConfig_CamelWords(t *testing.T) {
+	type MyConfig struct {
+		OAuthClientID string
+		GraphQLURL    string
+	}
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	c.NameCase = conf.NameCaseUpperSnake
+	names, err := c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"O_AUTH_CLIENT_ID", "GRAPH_QLURL"}, names)
+
+	c.CamelWords = []string{"OAuth", "GraphQL"}
+	names, err = c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"OAUTH_CLIENT_ID", "GRAPHQL_URL"}, names)
+}
+
+func TestCamelSplit(t *testing.T) {
+	tests := []struct {
+		src      string
+		words    []string
+		expected []string
+	}{
+		{src: "", expected: nil},
+		{src: "Host", expected: []string{"Host"}},
+		{src: "fooBar", expected: []string{"foo", "Bar"}},
+		{src: "ID", expected: []string{"ID"}},
+		{src: "CLIHost", expected: []string{"CLI", "Host"}},
+		{src: "HTTPSProxy", expected: []string{"HTTPS", "Proxy"}},
+		{src: "HTTPSPort", expected: []string{"HTTPS", "Port"}},
+		{src: "OAuth2", expected: []string{"O", "Auth2"}},
+		{src: "OAuth2", words: []string{"OAuth"}, expected: []string{"OAuth2"}},
+		{src: "OAuth2Token", words: []string{"OAuth"}, expected: []string{"OAuth2", "Token"}},
+		{src: "OAuthor", words: []string{"OAuth"}, expected: []string{"O", "Author"}},
+		{src: "GraphQLServer", words: []string{"OAuth", "GraphQL"}, expected: []string{"GraphQL", "Server"}},
+		{src: "UUIDv4", expected: []string{"UUID", "v4"}},
+		{src: "S3Bucket", expected: []string{"S3", "Bucket"}},
+		{src: "HTTP2Server", expected: []string{"HTTP2", "Server"}},
+		{src: "IDs", expected: []string{"IDs"}},
+		{src: "UserIDs", expected: []string{"User", "IDs"}},
+		{src: "IDsFound", expected: []string{"IDs", "Found"}},
+		{src: "Is", expected: []string{"Is"}},
+		{src: "2FACode", expected: []string{"2FA", "Code"}},
+		{src: "DB_Host", expected: []string{"DB", "Host"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			assert.Equal(t, tt.expected, conf.CamelSplitWords(tt.src, tt.words...))
+			if tt.words == nil {
+				assert.Equal(t, tt.expected, conf.CamelSplit(tt.src))
+			}
+		})
+	}
+}
+
+func TestConfig_NameCaseNoPrefix(t *testing.T) {
+	type DBConfig struct {
+		Host   string
+		Region string `conf:"no-prefix"`
+	}
+	type MyConfig struct {
+		DB       DBConfig `conf:"prefix:DB"`
+		LogLevel string   `conf:"no-prefix"`
+		Explicit string   `conf:"env:EXPLICIT,no-prefix"`
+	}
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	c.NameCase = conf.NameCaseUpperSnake
+
+	names, err := c.EnvNames()
+	require.NoError(t, err, "c.EnvNames is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HOST", "REGION", "LOG_LEVEL", "EXPLICIT"}, names)
+}
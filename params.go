@@ -0,0 +1,81 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/rsb/failure"
+)
+
+// Formats accepted by FormatParams
+const (
+	ParamFormatJSON   = "json"
+	ParamFormatAWSCLI = "aws-cli"
+	ParamFormatEnv    = "env"
+)
+
+// ParamEntry is one parameter in the json output of FormatParams, the
+// fields match ssm.PutParameterInput
+type ParamEntry struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+	Type  string `json:"Type"`
+}
+
+// FormatParams renders params, usually the result of CollectParamsFromEnv,
+// for seeding a new environment. The keys in secure, see SecureParamNames,
+// are typed SecureString and every other key String. Output is sorted by key.
+//
+//	json     a JSON array of ParamEntry
+//	aws-cli  one aws ssm put-parameter command per line
+//	env      KEY=VALUE lines named after the last segment of each key
+func FormatParams(params map[string]string, format string, secure ...string) ([]byte, error) {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	isSecure := map[string]bool{}
+	for _, k := range secure {
+		isSecure[k] = true
+	}
+
+	paramType := func(key string) string {
+		if isSecure[key] {
+			return ssm.ParameterTypeSecureString
+		}
+		return ssm.ParameterTypeString
+	}
+
+	var b strings.Builder
+	switch format {
+	case ParamFormatJSON:
+		entries := make([]ParamEntry, 0, len(keys))
+		for _, k := range keys {
+			entries = append(entries, ParamEntry{Name: k, Value: params[k], Type: paramType(k)})
+		}
+
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return nil, failure.ToSystem(err, "json.MarshalIndent failed")
+		}
+		return out, nil
+	case ParamFormatAWSCLI:
+		for _, k := range keys {
+			fmt.Fprintf(&b, "aws ssm put-parameter --name %s --value %s --type %s\n", shellQuote(k), shellQuote(params[k]), paramType(k))
+		}
+	case ParamFormatEnv:
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s=%s\n", path.Base(k), dotEnvQuote(params[k]))
+		}
+	default:
+		return nil, failure.Config("unsupported param format %q", format)
+	}
+
+	return []byte(b.String()), nil
+}
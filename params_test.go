@@ -0,0 +1,43 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatParams(t *testing.T) {
+	params := map[string]string{
+		"/my-app/DB_HOST": "db.internal",
+		"/my-app/DB_PASS": "it's secret",
+	}
+	secure := []string{"/my-app/DB_PASS"}
+
+	out, err := conf.FormatParams(params, conf.ParamFormatAWSCLI, secure...)
+	require.NoError(t, err, "conf.FormatParams is not expected to fail")
+	expected := "aws ssm put-parameter --name /my-app/DB_HOST --value db.internal --type String\n" +
+		"aws ssm put-parameter --name /my-app/DB_PASS --value 'it'\\''s secret' --type SecureString\n"
+	assert.Equal(t, expected, string(out))
+
+	out, err = conf.FormatParams(params, conf.ParamFormatEnv, secure...)
+	require.NoError(t, err, "conf.FormatParams is not expected to fail")
+	assert.Equal(t, "DB_HOST=db.internal\nDB_PASS=\"it's secret\"\n", string(out))
+
+	values, err := conf.ParseDotEnv(strings.NewReader(string(out)))
+	require.NoError(t, err, "conf.ParseDotEnv is not expected to fail")
+	assert.Equal(t, map[string]string{"DB_HOST": "db.internal", "DB_PASS": "it's secret"}, values)
+
+	out, err = conf.FormatParams(params, conf.ParamFormatJSON, secure...)
+	require.NoError(t, err, "conf.FormatParams is not expected to fail")
+	assert.JSONEq(t, `[
+		{"Name": "/my-app/DB_HOST", "Value": "db.internal", "Type": "String"},
+		{"Name": "/my-app/DB_PASS", "Value": "it's secret", "Type": "SecureString"}
+	]`, string(out))
+
+	_, err = conf.FormatParams(params, "yaml")
+	require.Error(t, err, "conf.FormatParams is expected to fail")
+	assert.Contains(t, err.Error(), `unsupported param format "yaml"`)
+}
@@ -0,0 +1,362 @@
+package conf
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/rsb/failure"
+)
+
+// MaxGetParametersNames is the most names ssm.GetParameters accepts in a
+// single call
+const MaxGetParametersNames = 10
+
+// PStore loads configuration from AWS SSM Parameter Store. Fields are keyed
+// with PStoreKey, the same keys CollectParamsFromEnv produces.
+type PStore struct {
+	API ssmiface.SSMAPI
+
+	// ExcludedVars are skipped the same way CollectParamsFromEnv skips them.
+	// NewPStore sets it to DefaultExcludedVars.
+	ExcludedVars []string
+
+	// Decrypt sets WithDecryption for every parameter so SecureString values
+	// come back in plain text. Without it only fields tagged pstore-secure or
+	// mask are decrypted.
+	Decrypt bool
+
+	// Retry is applied around every call to ssm. The zero value makes a
+	// single attempt, NewPStore sets it to DefaultRetryPolicy.
+	Retry RetryPolicy
+
+	// Timeout bounds ProcessParamStore and Lookup as a whole, including
+	// retries. There is no timeout when it is zero.
+	Timeout time.Duration
+}
+
+// RetryPolicy controls how PStore retries calls that were throttled or
+// failed with a 5xx. Any other failure, like ParameterNotFound, is returned
+// right away.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of calls made, one or less disables
+	// retries
+	MaxAttempts int
+
+	// BaseBackoff is the wait before the first retry, it doubles for every
+	// retry after that
+	BaseBackoff time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseBackoff: 100 * time.Millisecond}
+}
+
+func NewPStore(api ssmiface.SSMAPI) *PStore {
+	return &PStore{API: api, ExcludedVars: DefaultExcludedVars(), Retry: DefaultRetryPolicy()}
+}
+
+// ProcessParamStore populates spec from the parameter store. Every key is
+// collected up front and fetched with ssm.GetParameters in batches of
+// MaxGetParametersNames, then the results are mapped back onto the fields
+// with the same default and required rules as Process. Keys that need
+// decryption, see PStore.Decrypt, are fetched in their own batches.
+func ProcessParamStore(ctx context.Context, ps *PStore, appTitle string, spec interface{}, prefix ...string) error {
+	return processParamStore(ctx, ps, appTitle, spec, defaultOptions(), prefix...)
+}
+
+// processParamStore uses the excluded vars of ps rather than those in opts
+func processParamStore(ctx context.Context, ps *PStore, appTitle string, spec interface{}, opts options, prefix ...string) error {
+	if appTitle == "" {
+		return failure.System("appTitle is empty")
+	}
+
+	ctx, cancel := ps.withTimeout(ctx)
+	defer cancel()
+
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	var plain, secure []string
+	for _, field := range fields {
+		env := field.EnvVariable()
+		key := PStoreKey(field, appTitle, env)
+		if env == "-" || key == "-" || isExcluded(env, ps.ExcludedVars) {
+			continue
+		}
+
+		if env == "" {
+			return failure.System("env: is required but empty for (%s)", field.Path)
+		}
+
+		if ps.Decrypt || field.IsPStoreSecure() || field.IsMasked() {
+			secure = append(secure, key)
+		} else {
+			plain = append(plain, key)
+		}
+	}
+
+	params, err := ps.GetParameters(ctx, plain, false)
+	if err != nil {
+		return failure.Wrap(err, "ps.GetParameters failed")
+	}
+
+	decrypted, err := ps.GetParameters(ctx, secure, true)
+	if err != nil {
+		return failure.Wrap(err, "ps.GetParameters failed (decrypt)")
+	}
+
+	for k, v := range decrypted {
+		params[k] = v
+	}
+
+	src := SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		env := field.EnvVariable()
+		if isExcluded(env, ps.ExcludedVars) {
+			return "", false, nil
+		}
+
+		value, ok := params[PStoreKey(field, appTitle, env)]
+		return value, ok, nil
+	})
+
+	return process(ctx, spec, []Source{src}, opts, prefix...)
+}
+
+// ParamStoreSource resolves fields from the parameter store one key at a
+// time, so SSM can be given a place in the precedence of Process. Keys are
+// built with PStoreKey and decrypted like ProcessParamStore does. Prefer
+// ProcessParamStore when the parameter store is the only source, it fetches
+// in batches.
+func ParamStoreSource(ps *PStore, appTitle string) Source {
+	return SourceFunc(func(ctx context.Context, field Field) (string, bool, error) {
+		if appTitle == "" {
+			return "", false, failure.System("appTitle is empty")
+		}
+
+		env := field.EnvVariable()
+		key := PStoreKey(field, appTitle, env)
+		if env == "" || env == "-" || key == "-" || isExcluded(env, ps.ExcludedVars) {
+			return "", false, nil
+		}
+
+		decrypt := ps.Decrypt || field.IsPStoreSecure() || field.IsMasked()
+		params, err := ps.GetParameters(ctx, []string{key}, decrypt)
+		if err != nil {
+			return "", false, failure.Wrap(err, "ps.GetParameters failed")
+		}
+
+		value, ok := params[key]
+		return value, ok, nil
+	})
+}
+
+// GetParameters fetches names in batches and returns the values by name.
+// Names that do not exist are left out of the result. decrypt sets
+// WithDecryption on each call.
+func (ps *PStore) GetParameters(ctx context.Context, names []string, decrypt bool) (map[string]string, error) {
+	result := map[string]string{}
+	names = uniqueStrings(names)
+
+	for start := 0; start < len(names); start += MaxGetParametersNames {
+		end := start + MaxGetParametersNames
+		if end > len(names) {
+			end = len(names)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return result, failure.ToTimeout(err, "context is done")
+		}
+
+		in := ssm.GetParametersInput{
+			Names:          aws.StringSlice(names[start:end]),
+			WithDecryption: aws.Bool(decrypt),
+		}
+		var out *ssm.GetParametersOutput
+		err := ps.retry(ctx, func() error {
+			var err error
+			out, err = ps.API.GetParametersWithContext(ctx, &in)
+			return err
+		})
+		if err != nil {
+			return result, failure.ToSystem(err, "ssm.GetParameters failed")
+		}
+
+		for _, p := range out.Parameters {
+			result[aws.StringValue(p.Name)] = aws.StringValue(p.Value)
+		}
+	}
+
+	return result, nil
+}
+
+// Lookup fetches a single decrypted parameter by name, which makes PStore a
+// SecretSource. Give secret fields the full parameter name with the secret
+// tag. ProcessParamStore is still the better fit for a whole spec since it
+// fetches in batches.
+func (ps *PStore) Lookup(key string) (string, bool, error) {
+	ctx, cancel := ps.withTimeout(context.Background())
+	defer cancel()
+
+	params, err := ps.GetParameters(ctx, []string{key}, true)
+	if err != nil {
+		return "", false, failure.Wrap(err, "ps.GetParameters failed")
+	}
+
+	value, ok := params[key]
+	return value, ok, nil
+}
+
+func (ps *PStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ps.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, ps.Timeout)
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// retryable or runs out of attempts, waiting between attempts as set by
+// ps.Retry
+func (ps *PStore) retry(ctx context.Context, fn func() error) error {
+	attempts := ps.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	backoff := ps.Retry.BaseBackoff
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return failure.ToTimeout(ctx.Err(), "context is done, last error: %v", err)
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+
+		if err = fn(); err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// isRetryable reports whether err is aws throttling or a 5xx response
+func isRetryable(err error) bool {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() >= 500 {
+		return true
+	}
+
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && request.IsErrorThrottle(awsErr)
+}
+
+// PushOptions controls how PushParamsToStore writes parameters
+type PushOptions struct {
+	// Secure lists the keys stored as SecureString, every other key is
+	// stored as String. SecureParamNames builds it from a spec.
+	Secure []string
+
+	// Overwrite replaces parameters that already exist
+	Overwrite bool
+
+	// KMSKeyID is the key used to encrypt SecureString parameters, the
+	// account default key is used when it is empty
+	KMSKeyID string
+}
+
+// PushParamsToStore puts every parameter in params, usually the result of
+// CollectParamsFromEnv, into the parameter store. A failure for one key does
+// not stop the others, all of them are returned as a failure.Multi.
+func PushParamsToStore(api ssmiface.SSMAPI, params map[string]string, opts PushOptions) error {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	secure := map[string]bool{}
+	for _, k := range opts.Secure {
+		secure[k] = true
+	}
+
+	var failed *failure.Multi
+	for _, key := range keys {
+		in := ssm.PutParameterInput{
+			Name:      aws.String(key),
+			Value:     aws.String(params[key]),
+			Overwrite: aws.Bool(opts.Overwrite),
+			Type:      aws.String(ssm.ParameterTypeString),
+		}
+
+		if secure[key] {
+			in.Type = aws.String(ssm.ParameterTypeSecureString)
+			if opts.KMSKeyID != "" {
+				in.KeyId = aws.String(opts.KMSKeyID)
+			}
+		}
+
+		if _, err := api.PutParameter(&in); err != nil {
+			failed = failure.Append(failed, failure.ToSystem(err, "ssm.PutParameter failed (%s)", key))
+		}
+	}
+
+	return failed.ErrorOrNil()
+}
+
+// SecureParamNames returns the keys of the fields that should be stored as
+// SecureString, which are those tagged mask or pstore-secure
+func SecureParamNames(appTitle string, spec interface{}, prefix ...string) ([]string, error) {
+	if appTitle == "" {
+		return nil, failure.System("appTitle is empty")
+	}
+
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	var result []string
+	for _, field := range fields {
+		if !field.IsMasked() && !field.IsPStoreSecure() {
+			continue
+		}
+
+		key := PStoreKey(field, appTitle, field.EnvVariable())
+		if key == "-" {
+			continue
+		}
+
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+func uniqueStrings(items []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+
+	return result
+}
@@ -0,0 +1,256 @@
+package conf_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSM serves parameters from a map and records the names requested in
+// each GetParameters call. Each call fails with the next of errs until they
+// run out.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	params    map[string]string
+	errs      []error
+	calls     [][]string
+	decrypted []string
+	puts      []*ssm.PutParameterInput
+}
+
+func (f *fakeSSM) PutParameter(in *ssm.PutParameterInput) (*ssm.PutParameterOutput, error) {
+	if aws.StringValue(in.Name) == "/my-app/FAIL" {
+		return nil, errors.New("access denied")
+	}
+
+	f.puts = append(f.puts, in)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeSSM) GetParametersWithContext(_ aws.Context, in *ssm.GetParametersInput, _ ...request.Option) (*ssm.GetParametersOutput, error) {
+	names := aws.StringValueSlice(in.Names)
+	f.calls = append(f.calls, names)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	if aws.BoolValue(in.WithDecryption) {
+		f.decrypted = append(f.decrypted, names...)
+	}
+
+	out := ssm.GetParametersOutput{}
+	for _, name := range names {
+		value, ok := f.params[name]
+		if !ok {
+			out.InvalidParameters = append(out.InvalidParameters, aws.String(name))
+			continue
+		}
+		out.Parameters = append(out.Parameters, &ssm.Parameter{Name: aws.String(name), Value: aws.String(value)})
+	}
+
+	return &out, nil
+}
+
+func TestProcessParamStore(t *testing.T) {
+	type MyConfig struct {
+		Host    string `conf:"env:DB_HOST,required"`
+		Port    int    `conf:"env:DB_PORT,default:5432"`
+		Region  string `conf:"env:REGION,pstore-global"`
+		Custom  string `conf:"env:CUSTOM,pstore:/shared/custom"`
+		Missing string `conf:"env:MISSING"`
+	}
+
+	api := &fakeSSM{params: map[string]string{
+		"/my-app/DB_HOST": "db.internal",
+		"/global/REGION":  "us-east-1",
+		"/shared/custom":  "value",
+	}}
+
+	var config MyConfig
+	err := conf.ProcessParamStore(context.Background(), conf.NewPStore(api), "my-app", &config)
+	require.NoError(t, err, "conf.ProcessParamStore is not expected to fail")
+	assert.Equal(t, "db.internal", config.Host)
+	assert.Equal(t, 5432, config.Port)
+	assert.Equal(t, "us-east-1", config.Region)
+	assert.Equal(t, "value", config.Custom)
+	assert.Empty(t, config.Missing)
+	assert.Len(t, api.calls, 1)
+}
+
+func TestProcessParamStore_RequiredMissing(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:DB_HOST,required"`
+	}
+
+	api := &fakeSSM{params: map[string]string{}}
+
+	var config MyConfig
+	err := conf.ProcessParamStore(context.Background(), conf.NewPStore(api), "my-app", &config)
+	require.Error(t, err, "conf.ProcessParamStore is expected to fail")
+	assert.Contains(t, err.Error(), "required key (Host,DB_HOST) missing value")
+}
+
+func TestPStore_GetParametersBatches(t *testing.T) {
+	params := map[string]string{}
+	var names []string
+	for i := 0; i < 23; i++ {
+		name := "/app/" + string(rune('A'+i))
+		params[name] = name
+		names = append(names, name)
+	}
+	names = append(names, "/app/A")
+
+	api := &fakeSSM{params: params}
+	result, err := conf.NewPStore(api).GetParameters(context.Background(), names, false)
+	require.NoError(t, err, "GetParameters is not expected to fail")
+	assert.Equal(t, params, result)
+	require.Len(t, api.calls, 3)
+	assert.Len(t, api.calls[0], conf.MaxGetParametersNames)
+	assert.Len(t, api.calls[2], 3)
+}
+
+func TestPStore_GetParametersContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	api := &fakeSSM{params: map[string]string{}}
+	_, err := conf.NewPStore(api).GetParameters(ctx, []string{"/app/A"}, false)
+	require.Error(t, err, "GetParameters is expected to fail")
+	assert.Contains(t, err.Error(), "context is done")
+	assert.Empty(t, api.calls)
+}
+
+func TestProcessParamStore_Decrypt(t *testing.T) {
+	type MyConfig struct {
+		Host   string `conf:"env:DB_HOST"`
+		Pass   string `conf:"env:DB_PASS,mask"`
+		APIKey string `conf:"env:API_KEY,pstore-secure"`
+	}
+
+	api := &fakeSSM{params: map[string]string{
+		"/my-app/DB_HOST": "db.internal",
+		"/my-app/DB_PASS": "s3cret",
+		"/my-app/API_KEY": "key",
+	}}
+
+	var config MyConfig
+	err := conf.ProcessParamStore(context.Background(), conf.NewPStore(api), "my-app", &config)
+	require.NoError(t, err, "conf.ProcessParamStore is not expected to fail")
+	assert.Equal(t, "s3cret", config.Pass)
+	assert.Equal(t, "key", config.APIKey)
+	assert.Equal(t, []string{"/my-app/DB_PASS", "/my-app/API_KEY"}, api.decrypted)
+
+	api.decrypted = nil
+	ps := conf.NewPStore(api)
+	ps.Decrypt = true
+	err = conf.ProcessParamStore(context.Background(), ps, "my-app", &config)
+	require.NoError(t, err, "conf.ProcessParamStore is not expected to fail")
+	assert.Equal(t, []string{"/my-app/DB_HOST", "/my-app/DB_PASS", "/my-app/API_KEY"}, api.decrypted)
+}
+
+func TestPushParamsToStore(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:DB_HOST"`
+		Pass string `conf:"env:DB_PASS,mask"`
+	}
+
+	var config MyConfig
+	secure, err := conf.SecureParamNames("my-app", &config)
+	require.NoError(t, err, "conf.SecureParamNames is not expected to fail")
+	assert.Equal(t, []string{"/my-app/DB_PASS"}, secure)
+
+	api := &fakeSSM{}
+	params := map[string]string{
+		"/my-app/DB_HOST": "db.internal",
+		"/my-app/DB_PASS": "s3cret",
+		"/my-app/FAIL":    "x",
+	}
+	opts := conf.PushOptions{Secure: secure, Overwrite: true, KMSKeyID: "alias/app"}
+	err = conf.PushParamsToStore(api, params, opts)
+	require.Error(t, err, "conf.PushParamsToStore is expected to fail")
+	assert.Contains(t, err.Error(), "ssm.PutParameter failed (/my-app/FAIL)")
+
+	require.Len(t, api.puts, 2)
+	host, pass := api.puts[0], api.puts[1]
+	assert.Equal(t, "/my-app/DB_HOST", aws.StringValue(host.Name))
+	assert.Equal(t, ssm.ParameterTypeString, aws.StringValue(host.Type))
+	assert.Nil(t, host.KeyId)
+	assert.True(t, aws.BoolValue(host.Overwrite))
+	assert.Equal(t, "s3cret", aws.StringValue(pass.Value))
+	assert.Equal(t, ssm.ParameterTypeSecureString, aws.StringValue(pass.Type))
+	assert.Equal(t, "alias/app", aws.StringValue(pass.KeyId))
+}
+
+func TestPStore_Lookup(t *testing.T) {
+	type MyConfig struct {
+		Pass string `conf:"env:DB_PASS,secret:/my-app/DB_PASS,mask"`
+		Key  string `conf:"env:API_KEY,secret:/my-app/API_KEY,mask"`
+	}
+
+	api := &fakeSSM{params: map[string]string{"/my-app/DB_PASS": "s3cret"}}
+
+	var config MyConfig
+	err := conf.ProcessWithSource(conf.NewPStore(api), &config)
+	require.NoError(t, err)
+	assert.Equal(t, MyConfig{Pass: "s3cret"}, config)
+	assert.Equal(t, []string{"/my-app/DB_PASS", "/my-app/API_KEY"}, api.decrypted)
+}
+
+func TestPStore_Retry(t *testing.T) {
+	throttled := awserr.New("ThrottlingException", "rate exceeded", nil)
+	unavailable := awserr.NewRequestFailure(awserr.New("InternalServerError", "unavailable", nil), 503, "req-1")
+	notFound := awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+	policy := conf.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond}
+
+	api := &fakeSSM{params: map[string]string{"/app/A": "a"}, errs: []error{throttled, unavailable}}
+	ps := conf.NewPStore(api)
+	ps.Retry = policy
+	result, err := ps.GetParameters(context.Background(), []string{"/app/A"}, false)
+	require.NoError(t, err, "GetParameters is not expected to fail")
+	assert.Equal(t, map[string]string{"/app/A": "a"}, result)
+	assert.Len(t, api.calls, 3)
+
+	api = &fakeSSM{errs: []error{notFound, throttled}}
+	ps = conf.NewPStore(api)
+	ps.Retry = policy
+	_, err = ps.GetParameters(context.Background(), []string{"/app/A"}, false)
+	require.Error(t, err, "GetParameters is expected to fail")
+	assert.Contains(t, err.Error(), "ParameterNotFound")
+	assert.Len(t, api.calls, 1)
+
+	api = &fakeSSM{errs: []error{throttled, throttled}}
+	ps = conf.NewPStore(api)
+	ps.Retry = conf.RetryPolicy{}
+	_, err = ps.GetParameters(context.Background(), []string{"/app/A"}, false)
+	require.Error(t, err, "GetParameters is expected to fail without retries")
+	assert.Len(t, api.calls, 1)
+}
+
+func TestProcessParamStore_Timeout(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:DB_HOST"`
+	}
+
+	throttled := awserr.New("ThrottlingException", "rate exceeded", nil)
+	api := &fakeSSM{errs: []error{throttled, throttled}}
+	ps := conf.NewPStore(api)
+	ps.Retry = conf.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Minute}
+	ps.Timeout = 10 * time.Millisecond
+
+	var config MyConfig
+	err := conf.ProcessParamStore(context.Background(), ps, "my-app", &config)
+	require.Error(t, err, "conf.ProcessParamStore is expected to fail")
+	assert.Contains(t, err.Error(), "context is done")
+	assert.Len(t, api.calls, 1)
+}
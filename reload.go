@@ -0,0 +1,122 @@
+package conf
+
+import (
+	"reflect"
+
+	"github.com/rsb/failure"
+)
+
+// Reprocess re-reads the environment into Data, typically on SIGHUP. Only
+// fields whose env var (or _FILE for from-file fields) is currently set are
+// overwritten, every other field keeps its prior value and defaults are not
+// re-applied.
+//
+// The new values are built on a copy of Data and, once every field has been
+// processed and Validate passes, copied over Data while holding a write lock.
+// On failure Data is left untouched. Code that reads Data concurrently with
+// Reprocess must do so inside View, reads outside of it are not synchronized.
+func (c *Config) Reprocess() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// origins are only recorded once the new values are in place
+	var changed []Field
+	err := c.run("reprocessEnv", func(prefix []string) error {
+		opts := c.options()
+		opts.record = func(field Field, _ ValueSource) { changed = append(changed, field) }
+		return reprocessEnv(c.Data, opts, prefix...)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, field := range changed {
+		c.recordOrigin(field, FromEnv)
+	}
+
+	return nil
+}
+
+// View runs fn while holding a read lock, so Data cannot be swapped by
+// Reprocess until fn returns. fn must not call Reprocess.
+func (c *Config) View(fn func()) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	fn()
+}
+
+func reprocessEnv(spec interface{}, opts options, prefix ...string) error {
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr || s.Elem().Kind() != reflect.Struct {
+		return InvalidSpecFailure
+	}
+
+	next := reflect.New(s.Elem().Type())
+	next.Elem().Set(s.Elem())
+	detachPtrs(next.Elem())
+
+	fields, err := specFields(next.Interface(), opts, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	var failed *failure.Multi
+	for _, field := range fields {
+		if field.IsArg() {
+			continue
+		}
+
+		value, items, ok, err := opts.lookupField(field)
+		if err != nil {
+			err = failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonLookup, err))
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		opts.recordOrigin(field, FromEnv)
+		if err = processValue(value, items, field, opts.lookupEnv()); err != nil {
+			err = failure.Wrap(err, "ProcessField failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonInvalid, err))
+		}
+	}
+
+	if err = failed.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	if err = Validate(next.Interface()); err != nil {
+		return err
+	}
+
+	s.Elem().Set(next.Elem())
+	return nil
+}
+
+// detachPtrs replaces the pointers of a shallow copy with pointers to
+// copies, nested structs included, so processing the copy never writes
+// through to the original
+func detachPtrs(s reflect.Value) {
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+
+		switch {
+		case f.Kind() == reflect.Ptr && !f.IsNil():
+			c := reflect.New(f.Type().Elem())
+			c.Elem().Set(f.Elem())
+			if c.Elem().Kind() == reflect.Struct && !isValueType(c.Elem()) {
+				detachPtrs(c.Elem())
+			}
+			f.Set(c)
+		case f.Kind() == reflect.Struct && !isValueType(f):
+			detachPtrs(f)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ReloadDB struct {
+	Host string `conf:"env:HOST"`
+}
+
+type ReloadConfig struct {
+	Port    int       `conf:"env:PORT,default:8080"`
+	Level   string    `conf:"env:LEVEL,default:info"`
+	Timeout *int      `conf:"env:TIMEOUT"`
+	DB      *ReloadDB `conf:"prefix:DB"`
+}
+
+func TestConfig_Reprocess(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "APP_PORT", "9000")
+	setenv(t, "APP_LEVEL", "debug")
+	setenv(t, "APP_TIMEOUT", "5")
+	setenv(t, "APP_DB_HOST", "db-1")
+
+	var config ReloadConfig
+	c := conf.NewConfig(&config, "APP")
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+
+	os.Clearenv()
+	setenv(t, "APP_PORT", "9001")
+	setenv(t, "APP_DB_HOST", "db-2")
+	require.NoError(t, c.Reprocess(), "c.Reprocess is not expected to fail")
+
+	c.View(func() {
+		assert.Equal(t, 9001, config.Port)
+		assert.Equal(t, "debug", config.Level, "fields without a value keep their prior value")
+		require.NotNil(t, config.Timeout)
+		assert.Equal(t, 5, *config.Timeout)
+		assert.Equal(t, "db-2", config.DB.Host)
+	})
+	os.Clearenv()
+}
+
+func TestConfig_Reprocess_FailureLeavesDataUntouched(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "APP_TIMEOUT", "5")
+	setenv(t, "APP_DB_HOST", "db-1")
+
+	var config ReloadConfig
+	c := conf.NewConfig(&config, "APP")
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+	db := config.DB
+	timeout := config.Timeout
+
+	setenv(t, "APP_TIMEOUT", "10")
+	setenv(t, "APP_DB_HOST", "db-2")
+	setenv(t, "APP_PORT", "not-a-number")
+	err := c.Reprocess()
+	require.Error(t, err, "c.Reprocess is expected to fail")
+	assert.Contains(t, err.Error(), "ProcessField failed (Port)")
+
+	assert.Equal(t, 8080, config.Port)
+	assert.Equal(t, 5, *config.Timeout)
+	assert.Equal(t, "db-1", config.DB.Host)
+	assert.Same(t, db, config.DB)
+	assert.Same(t, timeout, config.Timeout)
+	os.Clearenv()
+}
@@ -0,0 +1,156 @@
+package conf
+
+import (
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ValueSource says where ProcessCLI found the value of a field
+type ValueSource int
+
+const (
+	FromNone ValueSource = iota
+	FromCLI
+	FromEnv
+	FromViper
+	FromDefault
+	// FromSource is used by Process, which cannot tell what kind of Source
+	// provided the value
+	FromSource
+)
+
+func (s ValueSource) String() string {
+	switch s {
+	case FromCLI:
+		return "cli"
+	case FromEnv:
+		return "env"
+	case FromViper:
+		return "viper"
+	case FromDefault:
+		return "default"
+	case FromSource:
+		return "source"
+	default:
+		return "none"
+	}
+}
+
+// Resolution is the value ProcessCLI would use for a field and where it
+// came from. Source is FromNone when nothing provided a value.
+type Resolution struct {
+	Value  string
+	Source ValueSource
+
+	// items are the numbered variables of an indexed field, Value has them
+	// joined
+	items []string
+}
+
+// ResolveCLI is a dry run of ProcessCLI, it resolves every field with the
+// same precedence but leaves spec untouched. Results are keyed by Field.Path
+// like PrimaryDB.Host. Missing required fields are not an error here, they
+// simply resolve to FromNone.
+func ResolveCLI(cmd *cobra.Command, v *viper.Viper, spec interface{}, prefix ...string) (map[string]Resolution, error) {
+	return resolveCLIFields(cmd, v, spec, defaultOptions(), prefix...)
+}
+
+func resolveCLIFields(cmd *cobra.Command, v *viper.Viper, spec interface{}, opts options, prefix ...string) (map[string]Resolution, error) {
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	result := map[string]Resolution{}
+	for _, field := range fields {
+		res, err := resolveCLI(cmd, v, field, opts)
+		if err != nil {
+			return result, err
+		}
+
+		result[field.Path] = res
+	}
+
+	return result, nil
+}
+
+// Origins reports where each field got its value the last time ProcessEnv,
+// ProcessCLI, Process or Reprocess ran on this Config, keyed by Field.Path
+// like PrimaryDB.Host. Unlike Field.IsDefault, which only says a default exists,
+// FromDefault here means the default was actually used. Reprocess only
+// updates the fields it overwrote.
+func (c *Config) Origins() map[string]ValueSource {
+	c.originsMu.Lock()
+	defer c.originsMu.Unlock()
+
+	result := make(map[string]ValueSource, len(c.origins))
+	for k, v := range c.origins {
+		result[k] = v
+	}
+
+	return result
+}
+
+func (c *Config) recordOrigin(field Field, src ValueSource) {
+	c.originsMu.Lock()
+	defer c.originsMu.Unlock()
+
+	if c.origins == nil {
+		c.origins = map[string]ValueSource{}
+	}
+	c.origins[field.Path] = src
+}
+
+// resolveCLI applies the ProcessCLI precedence to a single field: the CLI
+// flag, then the env var, then viper and finally the default. The env var is
+// always looked up directly, viper is only asked when it is not set. Viper
+// means the config file, or any value viper has when opts.viperEnv is set.
+func resolveCLI(cmd *cobra.Command, v *viper.Viper, field Field, opts options) (Resolution, error) {
+	var res Resolution
+	env := field.EnvVariable()
+	flag := field.CLIFlag()
+
+	f := cmd.Flags().Lookup(flag)
+	// CLI flag has the highest priority
+	if flag != "" && f != nil && flagValue(f, field) != "" && f.Changed {
+		res = Resolution{Value: flagValue(f, field), Source: FromCLI}
+
+	} else if env != "" {
+		var ok bool
+		if env != "-" {
+			// Env is the 2nd highest priority
+			var err error
+			res.Value, res.items, ok, err = opts.lookupField(field)
+			if err != nil {
+				return res, failure.Wrap(err, "lookupEnv failed (%s)", field.Path)
+			}
+
+			if ok {
+				res.Source = FromEnv
+			}
+		}
+
+		if !ok {
+			// Env is missing or ignored, but we still need to check inside a
+			// config file
+			if opts.viperEnv {
+				res.Value, _ = fromViperAll(v, field)
+			} else {
+				res.Value, _ = fromViper(v, field)
+			}
+			res.Source = FromViper
+		}
+	}
+
+	// This will not happen if you use BindCLI because the default value is
+	// always set. It is here just in case you are doing things manually
+	if res.Value == "" {
+		res = Resolution{}
+		if field.IsDefault() {
+			res = Resolution{Value: field.DefaultValue(), Source: FromDefault}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,168 @@
+package conf_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveCLI(t *testing.T) {
+	type MyConfig struct {
+		Flag    string `conf:"env:MY_FLAG,cli:my-flag"`
+		Env     string `conf:"env:MY_ENV,cli:my-env"`
+		File    string `conf:"env:MY_FILE,cli:my-file"`
+		Default int    `conf:"env:MY_DEFAULT,cli:my-default,default:42"`
+		Missing string `conf:"env:MY_MISSING,cli:my-missing,required"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_FLAG", "from-env")
+	setenv(t, "MY_ENV", "from-env")
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader("myconfig:\n  my-file: from-file\n")))
+
+	var config MyConfig
+	var result map[string]conf.Resolution
+	cmd := &cobra.Command{Use: "my-cmd"}
+	cmd.RunE = func(_ *cobra.Command, _ []string) error {
+		var err error
+		result, err = conf.ResolveCLI(cmd, v, &config)
+		return err
+	}
+
+	require.NoError(t, conf.BindCLI(cmd, v, &config), "conf.BindCLI is not expected to fail")
+	cmd.SetArgs([]string{"--my-flag", "from-cli"})
+	require.NoError(t, cmd.Execute(), "cmd.Execute is not expected to fail")
+
+	expected := map[string]conf.Resolution{
+		"Flag":    {Value: "from-cli", Source: conf.FromCLI},
+		"Env":     {Value: "from-env", Source: conf.FromEnv},
+		"File":    {Value: "from-file", Source: conf.FromViper},
+		"Default": {Value: "42", Source: conf.FromDefault},
+		"Missing": {Source: conf.FromNone},
+	}
+	assert.Equal(t, expected, result)
+	assert.Equal(t, MyConfig{}, config, "ResolveCLI must not modify spec")
+	assert.Equal(t, "viper", conf.FromViper.String())
+	os.Clearenv()
+}
+
+func TestConfig_Origins(t *testing.T) {
+	type MyConfig struct {
+		Host    string `conf:"env:HOST"`
+		Port    int    `conf:"env:PORT,default:8080"`
+		Missing string `conf:"env:MISSING"`
+	}
+
+	os.Clearenv()
+	setenv(t, "HOST", "localhost")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	assert.Empty(t, c.Origins())
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+
+	expected := map[string]conf.ValueSource{
+		"Host":    conf.FromEnv,
+		"Port":    conf.FromDefault,
+		"Missing": conf.FromNone,
+	}
+	assert.Equal(t, expected, c.Origins())
+
+	setenv(t, "PORT", "9000")
+	require.NoError(t, c.Reprocess(), "c.Reprocess is not expected to fail")
+	assert.Equal(t, conf.FromEnv, c.Origins()["Port"])
+
+	src := conf.SourceFunc(func(_ context.Context, field conf.Field) (string, bool, error) {
+		return "example.com", field.Name == "Host", nil
+	})
+	require.NoError(t, c.Process(context.Background(), src), "c.Process is not expected to fail")
+	expected["Host"] = conf.FromSource
+	assert.Equal(t, expected, c.Origins())
+	os.Clearenv()
+}
+
+func TestFieldPathKeys_SameTypeTwice(t *testing.T) {
+	type DB struct {
+		Host string `conf:"env:HOST,cli:host"`
+	}
+	type MyConfig struct {
+		Primary DB `conf:"prefix:PRIMARY"`
+		Replica DB `conf:"prefix:REPLICA"`
+	}
+
+	os.Clearenv()
+	setenv(t, "PRIMARY_HOST", "primary.local")
+
+	var config MyConfig
+	c := conf.NewConfig(&config)
+	require.NoError(t, c.ProcessEnv(), "c.ProcessEnv is not expected to fail")
+
+	expected := map[string]conf.ValueSource{
+		"Primary.Host": conf.FromEnv,
+		"Replica.Host": conf.FromNone,
+	}
+	assert.Equal(t, expected, c.Origins())
+
+	result, err := conf.ResolveCLI(&cobra.Command{Use: "my-cmd"}, viper.New(), &config)
+	require.NoError(t, err, "conf.ResolveCLI is not expected to fail")
+	resolved := map[string]conf.Resolution{
+		"Primary.Host": {Value: "primary.local", Source: conf.FromEnv},
+		"Replica.Host": {Source: conf.FromNone},
+	}
+	assert.Equal(t, resolved, result)
+	os.Clearenv()
+}
+
+func TestConfig_ProcessCLI_ViperEnv(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:MY_HOST,cli:host,default:localhost"`
+		Port int    `conf:"env:MY_PORT,cli:port,default:80"`
+	}
+
+	os.Clearenv()
+	setenv(t, "LEGACY_HOST", "from-viper-env")
+	setenv(t, "LEGACY_PORT", "8080")
+	setenv(t, "MY_PORT", "9090")
+
+	tests := []struct {
+		name     string
+		viperEnv bool
+		expected MyConfig
+	}{
+		{name: "config file only", expected: MyConfig{Host: "localhost", Port: 9090}},
+		{name: "viper env", viperEnv: true, expected: MyConfig{Host: "from-viper-env", Port: 9090}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			require.NoError(t, v.BindEnv("myconfig.host", "LEGACY_HOST"))
+			require.NoError(t, v.BindEnv("myconfig.port", "LEGACY_PORT"))
+
+			var config MyConfig
+			c := conf.NewConfig(&config)
+			c.ViperEnv = tt.viperEnv
+
+			cmd := &cobra.Command{Use: "my-cmd"}
+			cmd.RunE = func(_ *cobra.Command, _ []string) error {
+				return c.ProcessCLI(cmd, v)
+			}
+
+			require.NoError(t, conf.BindCLI(cmd, v, &config))
+			cmd.SetArgs([]string{})
+			require.NoError(t, cmd.Execute())
+			assert.Equal(t, tt.expected, config, "the env var of the field wins over viper")
+		})
+	}
+	os.Clearenv()
+}
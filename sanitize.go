@@ -0,0 +1,52 @@
+package conf
+
+import "github.com/rsb/failure"
+
+// Sanitize returns a copy of env, like the result of Environ, with the value
+// of every variable read by a field tagged mask or no-print replaced by
+// MaskedValue. That includes env aliases, the _FILE companion of from-file
+// fields and the numbered variables of indexed fields. Keys the spec does
+// not read are kept as they are, use SanitizeKnown to drop them. It is meant
+// for logging the whole environment, on a crash for example.
+func Sanitize(spec interface{}, env map[string]string, prefix ...string) (map[string]string, error) {
+	return sanitize(spec, env, false, prefix...)
+}
+
+// SanitizeKnown is Sanitize that also drops every key spec does not read
+func SanitizeKnown(spec interface{}, env map[string]string, prefix ...string) (map[string]string, error) {
+	return sanitize(spec, env, true, prefix...)
+}
+
+func sanitize(spec interface{}, env map[string]string, dropUnknown bool, prefix ...string) (map[string]string, error) {
+	fields, err := Fields(spec, prefix...)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	claims := claimEnvVars(fields)
+	result := make(map[string]string, len(env))
+	for k, v := range env {
+		if _, known := claims.field(k); known || !dropUnknown {
+			result[k] = v
+		}
+	}
+	redactValues(result, claims, true)
+
+	return result, nil
+}
+
+// redactValues applies mask and no-print to values, which are keyed by env
+// var. Values of masked fields become MaskedValue and those of no-print
+// fields are removed, or masked as well when keepNoPrint is set.
+func redactValues(values map[string]string, claims envClaims, keepNoPrint bool) {
+	for k := range values {
+		field, ok := claims.field(k)
+		switch {
+		case !ok:
+		case field.IsNoPrint() && !keepNoPrint:
+			delete(values, k)
+		case field.IsMasked() || field.IsNoPrint():
+			values[k] = MaskedValue
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package conf_test
+
+import (
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitize(t *testing.T) {
+	type Credentials struct {
+		User     string `conf:"env:USER"`
+		Password string `conf:"env:PASSWORD"`
+	}
+	type MyConfig struct {
+		Host  string      `conf:"env:HOST"`
+		Key   string      `conf:"env:API_KEY,env-alias:KEY,mask"`
+		Token string      `conf:"env:TOKEN,no-print"`
+		DB    Credentials `conf:"prefix:DB,mask"`
+		Keys  []string    `conf:"env:KEYS,indexed,mask"`
+		Cert  string      `conf:"env:CERT,from-file,no-print"`
+	}
+
+	env := map[string]string{
+		"APP_HOST":        "localhost",
+		"APP_KEY":         "abc",
+		"APP_TOKEN":       "t0k3n",
+		"APP_DB_USER":     "admin",
+		"APP_DB_PASSWORD": "s3cret",
+		"APP_KEYS_0":      "k0",
+		"APP_KEYS_1":      "k1",
+		"APP_KEYS_X":      "other",
+		"APP_CERT_FILE":   "/run/secrets/cert",
+		"PATH":            "/usr/bin",
+	}
+
+	result, err := conf.Sanitize(&MyConfig{}, env, "APP")
+	require.NoError(t, err, "conf.Sanitize is not expected to fail")
+	expected := map[string]string{
+		"APP_HOST":        "localhost",
+		"APP_KEY":         conf.MaskedValue,
+		"APP_TOKEN":       conf.MaskedValue,
+		"APP_DB_USER":     conf.MaskedValue,
+		"APP_DB_PASSWORD": conf.MaskedValue,
+		"APP_KEYS_0":      conf.MaskedValue,
+		"APP_KEYS_1":      conf.MaskedValue,
+		"APP_KEYS_X":      "other",
+		"APP_CERT_FILE":   conf.MaskedValue,
+		"PATH":            "/usr/bin",
+	}
+	assert.Equal(t, expected, result)
+	assert.Equal(t, "s3cret", env["APP_DB_PASSWORD"], "env is not changed")
+
+	result, err = conf.SanitizeKnown(&MyConfig{}, env, "APP")
+	require.NoError(t, err, "conf.SanitizeKnown is not expected to fail")
+	delete(expected, "PATH")
+	delete(expected, "APP_KEYS_X")
+	assert.Equal(t, expected, result)
+
+	_, err = conf.Sanitize(MyConfig{}, env)
+	require.Error(t, err, "conf.Sanitize is expected to fail")
+	assert.ErrorIs(t, err, conf.InvalidSpecFailure)
+}
@@ -0,0 +1,89 @@
+package conf
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/rsb/failure"
+)
+
+// SecretSource is a secret backend, like Vault or GCP Secret Manager, that
+// ProcessWithSource resolves secret fields from. The bool reports whether
+// the key exists.
+type SecretSource interface {
+	Lookup(key string) (string, bool, error)
+}
+
+// SecretSourceFunc allows an ordinary function to be used as a SecretSource
+type SecretSourceFunc func(key string) (string, bool, error)
+
+func (fn SecretSourceFunc) Lookup(key string) (string, bool, error) {
+	return fn(key)
+}
+
+// ProcessWithSource populates the secret fields of spec, see Field.IsSecret,
+// from src using Field.SecretKey as the key. Defaults and required checks
+// apply to those fields like ProcessEnv, every other field is left alone and
+// Validate is not called, so run it after the rest of spec is populated.
+func ProcessWithSource(src SecretSource, spec interface{}, prefix ...string) error {
+	opts := defaultOptions()
+	opts.only = Field.IsSecret
+
+	lookup := SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		key := field.SecretKey()
+		if key == "" || key == "-" {
+			return "", false, nil
+		}
+
+		value, ok, err := src.Lookup(key)
+		if err != nil {
+			return "", false, failure.Wrap(err, "src.Lookup failed (%s)", key)
+		}
+
+		return value, ok, nil
+	})
+
+	if err := process(context.Background(), spec, []Source{lookup}, opts, prefix...); err != nil {
+		return failure.Wrap(err, "process failed")
+	}
+
+	return nil
+}
+
+// ProcessSecretsManager populates spec from a single Secrets Manager secret
+// whose value is a JSON object. Fields are looked up by Field.SecretKey and
+// go through the same processing as ProcessEnv, including defaults and
+// required checks.
+func ProcessSecretsManager(api secretsmanageriface.SecretsManagerAPI, secretID string, spec interface{}) error {
+	in := secretsmanager.GetSecretValueInput{SecretId: aws.String(secretID)}
+	out, err := api.GetSecretValue(&in)
+	if err != nil {
+		return failure.ToSystem(err, "secretsmanager.GetSecretValue failed (%s)", secretID)
+	}
+
+	var data map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(aws.StringValue(out.SecretString)))
+	dec.UseNumber()
+	if err = dec.Decode(&data); err != nil {
+		return failure.ToConfig(err, "json.Decode failed, secret (%s) is not a JSON object", secretID)
+	}
+
+	src := SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		item, ok := data[field.SecretKey()]
+		if !ok {
+			return "", false, nil
+		}
+
+		return stringifyValue(item, field), true, nil
+	})
+
+	if err = process(context.Background(), spec, []Source{src}, defaultOptions()); err != nil {
+		return failure.Wrap(err, "process failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package conf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(in *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := f.secrets[aws.StringValue(in.SecretId)]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func TestProcessSecretsManager(t *testing.T) {
+	type MyConfig struct {
+		User  string   `conf:"env:DB_USER,required"`
+		Pass  string   `conf:"env:DB_PASS,secret:password,mask"`
+		Port  int      `conf:"env:DB_PORT,default:5432"`
+		Hosts []string `conf:"env:DB_HOSTS"`
+	}
+
+	api := &fakeSecretsManager{secrets: map[string]string{
+		"app/db": `{"DB_USER": "admin", "password": "s3cret", "DB_HOSTS": ["a", "b"]}`,
+		"bad":    `not json`,
+		"empty":  `{}`,
+	}}
+
+	var config MyConfig
+	err := conf.ProcessSecretsManager(api, "app/db", &config)
+	require.NoError(t, err, "conf.ProcessSecretsManager is not expected to fail")
+	assert.Equal(t, "admin", config.User)
+	assert.Equal(t, "s3cret", config.Pass)
+	assert.Equal(t, 5432, config.Port)
+	assert.Equal(t, []string{"a", "b"}, config.Hosts)
+
+	err = conf.ProcessSecretsManager(api, "bad", &MyConfig{})
+	require.Error(t, err, "conf.ProcessSecretsManager is expected to fail")
+	assert.Contains(t, err.Error(), "secret (bad) is not a JSON object")
+
+	err = conf.ProcessSecretsManager(api, "empty", &MyConfig{})
+	require.Error(t, err, "conf.ProcessSecretsManager is expected to fail")
+	assert.Contains(t, err.Error(), "required key (User,DB_USER) missing value")
+
+	err = conf.ProcessSecretsManager(api, "missing", &MyConfig{})
+	require.Error(t, err, "conf.ProcessSecretsManager is expected to fail")
+	assert.Contains(t, err.Error(), "secretsmanager.GetSecretValue failed (missing)")
+}
+
+func TestProcessWithSource(t *testing.T) {
+	type MyConfig struct {
+		Host     string `conf:"env:DB_HOST,required"`
+		Pass     string `conf:"env:DB_PASS,mask,required"`
+		APIKey   string `conf:"env:API_KEY,secret:api/key"`
+		Signing  string `conf:"env:SIGNING_KEY,pstore-secure,default:dev-key"`
+		Region   string `conf:"env:REGION,pstore:region"`
+		Optional string `conf:"env:OPTIONAL,mask"`
+	}
+
+	secrets := map[string]string{
+		"DB_PASS": "s3cret",
+		"api/key": "abc123",
+		"DB_HOST": "ignored",
+		"REGION":  "us-east-1",
+	}
+	var keys []string
+	src := conf.SecretSourceFunc(func(key string) (string, bool, error) {
+		keys = append(keys, key)
+		value, ok := secrets[key]
+		return value, ok, nil
+	})
+
+	var config MyConfig
+	err := conf.ProcessWithSource(src, &config)
+	require.NoError(t, err)
+
+	expected := MyConfig{Pass: "s3cret", APIKey: "abc123", Signing: "dev-key", Region: "us-east-1"}
+	assert.Equal(t, expected, config, "only secret fields are populated")
+	assert.Equal(t, []string{"DB_PASS", "api/key", "SIGNING_KEY", "REGION", "OPTIONAL"}, keys)
+}
+
+func TestProcessWithSource_Failures(t *testing.T) {
+	type MyConfig struct {
+		Pass string `conf:"env:DB_PASS,mask,required"`
+	}
+
+	var config MyConfig
+	empty := conf.SecretSourceFunc(func(string) (string, bool, error) { return "", false, nil })
+	err := conf.ProcessWithSource(empty, &config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required key (Pass,DB_PASS) missing value")
+
+	broken := conf.SecretSourceFunc(func(string) (string, bool, error) { return "", false, errors.New("sealed") })
+	err = conf.ProcessWithSource(broken, &config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "src.Lookup failed (DB_PASS)")
+}
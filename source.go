@@ -0,0 +1,196 @@
+package conf
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Source is anything that can resolve a value for a field. The bool reports
+// whether the source had a value at all, which lets an empty string be a
+// legitimate value.
+type Source interface {
+	Lookup(ctx context.Context, field Field) (string, bool, error)
+}
+
+// SourceFunc allows an ordinary function to be used as a Source
+type SourceFunc func(ctx context.Context, field Field) (string, bool, error)
+
+func (fn SourceFunc) Lookup(ctx context.Context, field Field) (string, bool, error) {
+	return fn(ctx, field)
+}
+
+// itemSource is implemented by sources that can read the numbered
+// variables of an indexed field, so process keeps the items apart
+type itemSource interface {
+	lookupItems(field Field) ([]string, bool)
+}
+
+type envSource struct{}
+
+// EnvSource resolves fields from the process environment using the field's
+// env variable. Fields with no env or an env of "-" are never found.
+func EnvSource() Source {
+	return envSource{}
+}
+
+func (envSource) Lookup(_ context.Context, field Field) (string, bool, error) {
+	env := field.EnvVariable()
+	if env == "" || env == "-" {
+		return "", false, nil
+	}
+
+	return lookupVar(field, os.LookupEnv)
+}
+
+func (envSource) lookupItems(field Field) ([]string, bool) {
+	env := field.EnvVariable()
+	if env == "" || env == "-" {
+		return nil, false
+	}
+
+	return lookupIndexed(field, os.LookupEnv)
+}
+
+// CLISource resolves fields from the command line flags of cmd. Only flags
+// that were explicitly set by the user count as found, so defaults registered
+// by BindCLI do not shadow lower priority sources.
+func CLISource(cmd *cobra.Command) Source {
+	return SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		flag := field.CLIFlag()
+		if flag == "" || flag == "-" {
+			return "", false, nil
+		}
+
+		f := cmd.Flags().Lookup(flag)
+		if f == nil || !f.Changed {
+			return "", false, nil
+		}
+
+		return flagValue(f, field), true, nil
+	})
+}
+
+// ViperSource resolves fields from the config file loaded into v, using the
+// same key BindCLI binds the flag to.
+func ViperSource(v *viper.Viper) Source {
+	return SourceFunc(func(_ context.Context, field Field) (string, bool, error) {
+		if field.CLIFlag() == "" {
+			return "", false, nil
+		}
+
+		value, ok := fromViper(v, field)
+		return value, ok, nil
+	})
+}
+
+// Process resolves every field in spec from the given sources. Sources are
+// consulted in the order they are given, so the first source is the highest
+// priority, and the first one that has a value wins. When no source has a
+// value the default is used and only after that is the required check made,
+// including required-if, required-unless and required-env. Failures are
+// collected for all fields and returned as a single failure.Multi.
+//
+// The following is equivalent to the precedence used by ProcessCLI:
+//
+//	conf.Process(ctx, &config, conf.CLISource(cmd), conf.EnvSource(), conf.ViperSource(v))
+func Process(ctx context.Context, spec interface{}, sources ...Source) error {
+	return process(ctx, spec, sources, defaultOptions())
+}
+
+func process(ctx context.Context, spec interface{}, sources []Source, opts options, prefix ...string) error {
+	fields, err := specFields(spec, opts, prefix...)
+	if err != nil {
+		return failure.Wrap(err, "Fields failed")
+	}
+
+	// values are resolved for every field before any are processed so that
+	// required-if and required-unless can look at the other fields
+	var failed *failure.Multi
+	var pending []resolvedField
+	resolved := map[string]string{}
+	for _, field := range fields {
+		if field.IsArg() || (opts.only != nil && !opts.only(field)) {
+			continue
+		}
+
+		value, items, ok, err := lookupSources(ctx, field, sources)
+		if err != nil {
+			err = failure.Wrap(err, "source lookup failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonLookup, err))
+			continue
+		}
+
+		source := FromNone
+		switch {
+		case ok:
+			source = FromSource
+		case field.IsDefault():
+			value, ok, source = field.DefaultValue(), true, FromDefault
+		}
+
+		if ok {
+			resolved[field.EnvVariable()] = value
+			resolved[field.EnvVar] = value
+		}
+		pending = append(pending, resolvedField{Field: field, value: value, items: items, ok: ok, source: source})
+	}
+
+	for _, rf := range pending {
+		field := rf.Field
+		opts.recordOrigin(field, rf.source)
+		if !rf.ok {
+			key := field.Path + "," + field.EnvVariable()
+			if err = requiredFailure(field, key, resolved, opts); err != nil {
+				failed = failure.Append(failed, newConfigError(field, ReasonMissing, err))
+			}
+			continue
+		}
+
+		if err = processValue(rf.value, rf.items, field, opts.lookupEnv()); err != nil {
+			err = failure.Wrap(err, "ProcessField failed (%s)", field.Path)
+			failed = failure.Append(failed, newConfigError(field, ReasonInvalid, err))
+		}
+	}
+
+	if err = failed.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	if opts.only != nil {
+		return nil
+	}
+
+	return Validate(spec)
+}
+
+// lookupSources asks each source in turn, items is only set when an
+// itemSource found the numbered variables of an indexed field
+func lookupSources(ctx context.Context, field Field, sources []Source) (string, []string, bool, error) {
+	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return "", nil, false, failure.ToTimeout(err, "context is done")
+		}
+
+		value, ok, err := src.Lookup(ctx, field)
+		if err != nil {
+			return "", nil, false, err
+		}
+
+		if ok {
+			return value, nil, true, nil
+		}
+
+		if is, isItems := src.(itemSource); isItems && field.IsIndexed() {
+			if items, ok := is.lookupItems(field); ok {
+				return strings.Join(items, field.SliceDelimiter()), items, true, nil
+			}
+		}
+	}
+
+	return "", nil, false, nil
+}
@@ -0,0 +1,173 @@
+package conf_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mapSource(values map[string]string) conf.Source {
+	return conf.SourceFunc(func(_ context.Context, field conf.Field) (string, bool, error) {
+		value, ok := values[field.EnvVariable()]
+		return value, ok, nil
+	})
+}
+
+func TestProcess_Precedence(t *testing.T) {
+	type MyConfig struct {
+		Host  string `conf:"env:MY_HOST"`
+		Port  int    `conf:"env:MY_PORT,default:5432"`
+		Name  string `conf:"env:MY_NAME"`
+		Debug bool   `conf:"env:MY_DEBUG,default:true"`
+	}
+
+	high := mapSource(map[string]string{"MY_HOST": "high-host"})
+	low := mapSource(map[string]string{"MY_HOST": "low-host", "MY_NAME": "low-name", "MY_PORT": "1234"})
+
+	var config MyConfig
+	err := conf.Process(context.Background(), &config, high, low)
+	require.NoError(t, err, "conf.Process is not expected to fail")
+	assert.Equal(t, "high-host", config.Host)
+	assert.Equal(t, "low-name", config.Name)
+	assert.Equal(t, 1234, config.Port)
+	assert.True(t, config.Debug)
+}
+
+func TestProcess_AggregatesFailures(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:MY_HOST,required"`
+		Name string `conf:"env:MY_NAME,required"`
+		Port int    `conf:"env:MY_PORT"`
+	}
+
+	src := mapSource(map[string]string{"MY_PORT": "abc"})
+
+	var config MyConfig
+	err := conf.Process(context.Background(), &config, src)
+	require.Error(t, err, "conf.Process is expected to fail")
+
+	failures, ok := failure.MultiResult(err)
+	require.True(t, ok, "expected a failure.Multi")
+	assert.Len(t, failures, 3)
+	assert.Contains(t, err.Error(), "required key (Host,MY_HOST) missing value")
+	assert.Contains(t, err.Error(), "required key (Name,MY_NAME) missing value")
+	assert.Contains(t, err.Error(), "ProcessField failed (Port)")
+}
+
+func TestProcess_RequiredIf(t *testing.T) {
+	type MyConfig struct {
+		TLSEnabled bool   `conf:"env:TLS_ENABLED,default:false"`
+		TLSCert    string `conf:"env:TLS_CERT,required-if:TLS_ENABLED=true"`
+		Mode       string `conf:"env:MODE,default:local"`
+		Endpoint   string `conf:"env:ENDPOINT,required-unless:MODE=local"`
+	}
+
+	var config MyConfig
+	err := conf.Process(context.Background(), &config, mapSource(nil))
+	require.NoError(t, err, "conf.Process is not expected to fail when conditions are not met")
+
+	src := mapSource(map[string]string{"TLS_ENABLED": "true", "MODE": "remote"})
+	err = conf.Process(context.Background(), &MyConfig{}, src)
+	require.Error(t, err, "conf.Process is expected to fail when conditions are met")
+	assert.Contains(t, err.Error(), "required key (TLSCert,TLS_CERT) missing value, required-if (TLS_ENABLED=true)")
+	assert.Contains(t, err.Error(), "required key (Endpoint,ENDPOINT) missing value, required-unless (MODE=local)")
+}
+
+func TestProcess_SourceFailure(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:MY_HOST"`
+	}
+
+	src := conf.SourceFunc(func(_ context.Context, _ conf.Field) (string, bool, error) {
+		return "", false, failure.System("boom")
+	})
+
+	var config MyConfig
+	err := conf.Process(context.Background(), &config, src)
+	require.Error(t, err, "conf.Process is expected to fail")
+	assert.Contains(t, err.Error(), "source lookup failed (Host)")
+}
+
+func TestProcess_EnvAndCLISources(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:MY_HOST,cli:host"`
+		Name string `conf:"env:MY_NAME,cli:name"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_HOST", "env-host")
+	setenv(t, "MY_NAME", "env-name")
+
+	cmd := &cobra.Command{Use: "my-cmd"}
+	cmd.Flags().String("host", "", "")
+	cmd.Flags().String("name", "", "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--host", "cli-host"}))
+
+	var config MyConfig
+	err := conf.Process(context.Background(), &config, conf.CLISource(cmd), conf.EnvSource())
+	require.NoError(t, err, "conf.Process is not expected to fail")
+	assert.Equal(t, "cli-host", config.Host)
+	assert.Equal(t, "env-name", config.Name)
+	os.Clearenv()
+}
+
+func TestProcess_ParamStoreSource(t *testing.T) {
+	type MyConfig struct {
+		Host  string `conf:"env:MY_HOST,cli:host"`
+		Name  string `conf:"env:MY_NAME,cli:name"`
+		Pass  string `conf:"env:MY_PASS,cli:pass,mask"`
+		Port  int    `conf:"env:MY_PORT,cli:port,default:5432"`
+		Debug bool   `conf:"env:MY_DEBUG,cli:debug"`
+	}
+
+	os.Clearenv()
+	setenv(t, "MY_NAME", "env-name")
+	setenv(t, "MY_HOST", "env-host")
+
+	api := &fakeSSM{params: map[string]string{
+		"/my-app/MY_HOST": "ssm-host",
+		"/my-app/MY_NAME": "ssm-name",
+		"/my-app/MY_PASS": "ssm-pass",
+		"/my-app/MY_PORT": "6543",
+	}}
+
+	cmd := &cobra.Command{Use: "my-cmd"}
+	cmd.Flags().String("host", "", "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--host", "cli-host"}))
+
+	var config MyConfig
+	ps := conf.NewPStore(api)
+	err := conf.Process(context.Background(), &config, conf.CLISource(cmd), conf.EnvSource(), conf.ParamStoreSource(ps, "my-app"))
+	require.NoError(t, err, "conf.Process is not expected to fail")
+
+	expected := MyConfig{Host: "cli-host", Name: "env-name", Pass: "ssm-pass", Port: 6543}
+	assert.Equal(t, expected, config)
+	assert.Equal(t, []string{"/my-app/MY_PASS"}, api.decrypted)
+
+	err = conf.Process(context.Background(), &MyConfig{}, conf.ParamStoreSource(ps, ""))
+	require.Error(t, err, "conf.Process is expected to fail")
+	assert.Contains(t, err.Error(), "appTitle is empty")
+	os.Clearenv()
+}
+
+func TestConfig_Process_Prefix(t *testing.T) {
+	type MyConfig struct {
+		Host string `conf:"env:HOST"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_HOST", "prefixed-host")
+
+	var data MyConfig
+	c := conf.NewConfig(&data, "APP")
+	err := c.Process(context.Background(), conf.EnvSource())
+	require.NoError(t, err, "c.Process is not expected to fail")
+	assert.Equal(t, "prefixed-host", data.Host)
+	os.Clearenv()
+}
@@ -2,37 +2,200 @@ package conf
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/rsb/failure"
 )
 
+// Values accepted by the encoding tag for []byte fields
+const (
+	EncodingBase64 = "base64"
+	EncodingHex    = "hex"
+)
+
+// Values accepted by the flag-scope tag. Local is the default, global-flag
+// is an alias for flag-scope:persistent.
+const (
+	FlagScopeLocal      = "local"
+	FlagScopePersistent = "persistent"
+)
+
 // Tag represents the annotated tag `conf` used to control how we will
 // parse that property.
 type Tag struct {
 	EnvVar         string
+	EnvAliases     []string
 	CLIFlag        string
 	CLIShort       string
 	CLIUsage       string
+	Doc            string
+	Group          string
+	ScopeCmds      []string
+	Hidden         bool
+	Deprecated     string
+	MutexGroup     string
+	IsArg          bool
+	ArgIndex       int
 	PStoreVar      string
+	JSONKey        string
+	TOMLKey        string
+	YAMLKey        string
+	VaultKey       string
+	SecretKey      string
+	Prefix         string
 	IsPStoreGlobal bool
+	IsPStoreSecure bool
 	Default        string
+	Delimiter      string
+	MapPairSep     string
+	MapKVSep       string
+	Encoding       string
+	Min            string
+	Max            string
+	OneOf          []string
+	OneOfCI        bool
+	Regex          string
+	Len            string
 	IsCLIPFlag     bool
 	IsDefault      bool
 	NoCLIBind      bool
 	NoPrint        bool
 	NoPrefix       bool
 	Required       bool
+	RequiredIf     string
+	RequiredUnless string
+	RequiredEnv    []string
 	Mask           bool
+	FromFile       bool
+	Trim           bool
+	Size           bool
+	Base           int
+	BoolInt        bool
+	Unquote        bool
+	Indexed        bool
+	Expand         bool
+	ExpandStrict   bool
 }
 
+// StrictTags makes Fields, and everything built on it, parse tags with
+// ParseTagStrict so unknown tag keys are reported instead of ignored.
+var StrictTags = false
+
+// ParseTag parses the conf tag, unknown keys are ignored unless StrictTags
+// is enabled.
 func ParseTag(t string) (Tag, error) {
+	return parseTag(t, StrictTags)
+}
+
+// ParseTagStrict is like ParseTag but fails on any key it does not
+// recognize, which catches typos like requred or masked.
+func ParseTagStrict(t string) (Tag, error) {
+	return parseTag(t, true)
+}
+
+var (
+	tagPresetsMu sync.RWMutex
+	tagPresets   = map[string]string{}
+)
+
+// RegisterTagPreset registers a named set of tag options that any conf tag
+// can pull in with preset:name, e.g. a "secret" preset of
+// "required,mask,no-print". The preset options are applied before the rest
+// of the tag, so options given explicitly override them. Presets are
+// usually registered from init, before any spec is processed. Registering a
+// name again replaces the preset and the tags that use it are parsed again.
+func RegisterTagPreset(name, opts string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return failure.Config("preset name is empty")
+	}
+
+	expanded, err := expandPresets(opts)
+	if err != nil {
+		return failure.Wrap(err, "expandPresets failed (%s)", name)
+	}
+
+	if _, err = parseTag(expanded, StrictTags); err != nil {
+		return failure.Wrap(err, "preset (%s) is not a valid tag", name)
+	}
+
+	tagPresetsMu.Lock()
+	tagPresets[name] = expanded
+	tagPresetsMu.Unlock()
+
+	// cached layouts hold tags expanded with the old preset
+	resetLayoutCache()
+
+	return nil
+}
+
+// expandPresets replaces every preset:name in t with the options of the
+// preset and moves them in front of the explicit options
+func expandPresets(t string) (string, error) {
+	if !strings.Contains(t, "preset:") {
+		return t, nil
+	}
+
+	tagPresetsMu.RLock()
+	defer tagPresetsMu.RUnlock()
+
+	var presets, explicit []string
+	for _, part := range strings.Split(t, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if strings.TrimSpace(key) != "preset" {
+			explicit = append(explicit, part)
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		opts, ok := tagPresets[value]
+		if !ok {
+			return "", failure.Config("tag (preset) unknown preset %q", value)
+		}
+
+		if opts != "" {
+			presets = append(presets, opts)
+		}
+	}
+
+	return strings.Join(append(presets, explicit...), ","), nil
+}
+
+// docPattern finds the doc key, which must be the last key in a tag so its
+// value can contain commas
+var docPattern = regexp.MustCompile(`(^|,)\s*doc:`)
+
+// noCommaKeys are keys that, unlike doc, can appear anywhere in a tag so
+// their values can not contain a comma. An unknown key right after one of
+// them is the rest of a cut value and is reported instead of ignored.
+var noCommaKeys = map[string]bool{"regex": true, "deprecated": true}
+
+// cutDoc splits the doc key off the end of t
+func cutDoc(t string) (string, string) {
+	loc := docPattern.FindStringIndex(t)
+	if loc == nil {
+		return t, ""
+	}
+
+	return t[:loc[0]], strings.TrimSpace(t[loc[1]:])
+}
+
+func parseTag(t string, strict bool) (Tag, error) {
 	var tag Tag
 
+	t, tag.Doc = cutDoc(t)
 	if t == "" {
 		return tag, nil
 	}
 
+	t, err := expandPresets(t)
+	if err != nil {
+		return tag, err
+	}
+
+	var prev string
 	parts := strings.Split(t, ",")
 	for _, part := range parts {
 		vals := strings.SplitN(strings.TrimSpace(part), ":", 2)
@@ -54,6 +217,36 @@ func ParseTag(t string) (Tag, error) {
 				tag.Mask = true
 			case "pstore-global":
 				tag.IsPStoreGlobal = true
+			case "pstore-secure":
+				tag.IsPStoreSecure = true
+			case "oneof-ci":
+				tag.OneOfCI = true
+			case "from-file":
+				tag.FromFile = true
+			case "hidden":
+				tag.Hidden = true
+			case "trim":
+				tag.Trim = true
+			case "size":
+				tag.Size = true
+			case "bool-int":
+				tag.BoolInt = true
+			case "unquote":
+				tag.Unquote = true
+			case "indexed":
+				tag.Indexed = true
+			case "expand":
+				tag.Expand = true
+			case "expand-strict":
+				tag.Expand = true
+				tag.ExpandStrict = true
+			default:
+				if noCommaKeys[prev] {
+					return tag, failure.Config("tag (%s) value can not contain a comma", prev)
+				}
+				if strict && property != "" {
+					return tag, failure.Config("unknown tag key %q", property)
+				}
 			}
 		case 2:
 			value := vals[1]
@@ -75,16 +268,120 @@ func ParseTag(t string) (Tag, error) {
 
 			case "env":
 				tag.EnvVar = strings.TrimSpace(value)
-			case "cli":
+			case "env-alias":
+				for _, alias := range strings.Split(value, "|") {
+					tag.EnvAliases = append(tag.EnvAliases, strings.TrimSpace(alias))
+				}
+			case "cli", "cmds":
 				tag.CLIFlag = strings.TrimSpace(value)
-			case "cli-s":
+			case "cli-s", "cmds-s":
 				tag.CLIShort = strings.TrimSpace(value)
-			case "cli-u":
+			case "cli-u", "cmds-u":
 				tag.CLIUsage = strings.TrimSpace(value)
+			case "flag-scope":
+				switch strings.TrimSpace(value) {
+				case FlagScopePersistent:
+					tag.IsCLIPFlag = true
+				case FlagScopeLocal:
+					tag.IsCLIPFlag = false
+				default:
+					return tag, failure.Config("tag (flag-scope) unsupported value %q", value)
+				}
+			case "deprecated":
+				tag.Deprecated = strings.TrimSpace(value)
+			case "mutex-group":
+				tag.MutexGroup = strings.TrimSpace(value)
+			case "arg":
+				index, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil || index < 0 {
+					return tag, failure.Config("tag (arg) invalid index %q", value)
+				}
+				tag.IsArg = true
+				tag.ArgIndex = index
+			case "base":
+				base, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil || base < 2 || base > 36 {
+					return tag, failure.Config("tag (base) invalid value %q", value)
+				}
+				tag.Base = base
+			case "group":
+				tag.Group = strings.TrimSpace(value)
+			case "scope-cmds":
+				for _, name := range strings.Split(value, "|") {
+					tag.ScopeCmds = append(tag.ScopeCmds, strings.TrimSpace(name))
+				}
 			case "pstore":
 				tag.PStoreVar = strings.TrimSpace(value)
+			case "json":
+				tag.JSONKey = strings.TrimSpace(value)
+			case "toml":
+				tag.TOMLKey = strings.TrimSpace(value)
+			case "yaml":
+				tag.YAMLKey = strings.TrimSpace(value)
+			case "vault":
+				tag.VaultKey = strings.TrimSpace(value)
+			case "secret":
+				tag.SecretKey = strings.TrimSpace(value)
+			case "prefix":
+				tag.Prefix = strings.TrimSpace(value)
+			case "delim":
+				tag.Delimiter = value
+			case "map-pair-sep":
+				tag.MapPairSep = value
+			case "map-kv-sep":
+				tag.MapKVSep = value
+			case "encoding":
+				tag.Encoding = strings.TrimSpace(value)
+				if tag.Encoding != EncodingBase64 && tag.Encoding != EncodingHex {
+					return tag, failure.Config("tag (encoding) unsupported value %q", tag.Encoding)
+				}
+			case "min":
+				tag.Min = strings.TrimSpace(value)
+			case "max":
+				tag.Max = strings.TrimSpace(value)
+			case "required-if", "required-unless":
+				value = strings.TrimSpace(value)
+				if !strings.Contains(value, "=") {
+					return tag, failure.Config("tag (%q) must be in the form KEY=VALUE", property)
+				}
+				if property == "required-if" {
+					tag.RequiredIf = value
+				} else {
+					tag.RequiredUnless = value
+				}
+			case "required-env":
+				for _, name := range strings.Split(value, "|") {
+					tag.RequiredEnv = append(tag.RequiredEnv, strings.TrimSpace(name))
+				}
+			case "regex":
+				if _, err := compileRegex(value); err != nil {
+					return tag, failure.ToConfig(err, "tag (regex) invalid pattern %q", value)
+				}
+				tag.Regex = value
+			case "len":
+				value = strings.TrimSpace(value)
+				if _, _, err := parseLen(value); err != nil {
+					return tag, failure.Wrap(err, "tag (len) invalid value %q", value)
+				}
+				tag.Len = value
+			case "oneof":
+				for _, item := range strings.Split(value, "|") {
+					tag.OneOf = append(tag.OneOf, strings.TrimSpace(item))
+				}
+			default:
+				if noCommaKeys[prev] {
+					return tag, failure.Config("tag (%s) value can not contain a comma", prev)
+				}
+				if strict {
+					return tag, failure.Config("unknown tag key %q", property)
+				}
 			}
 		}
+		prev = property
+	}
+
+	if tag.Required && tag.IsDefault {
+		return tag, failure.Config("tag has both required and default (mutually exclusive)")
 	}
 
 	return tag, nil
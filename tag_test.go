@@ -31,6 +31,116 @@ func TestParseTag_Success(t *testing.T) {
 				Mask:      false,
 			},
 		},
+		{
+			name: "cmds keys are aliases for cli keys",
+			tag:  "env:FOO_BAR,cmds:foo-bar,cmds-s:f,cmds-u:some usage,pstore:/app/foo,global-flag",
+			expected: conf.Tag{
+				EnvVar:     "FOO_BAR",
+				CLIFlag:    "foo-bar",
+				CLIShort:   "f",
+				CLIUsage:   "some usage",
+				PStoreVar:  "/app/foo",
+				IsCLIPFlag: true,
+			},
+		},
+		{
+			name: "env aliases",
+			tag:  "env:DB_HOST,env-alias:OLD_DB_HOST|LEGACY_HOST",
+			expected: conf.Tag{
+				EnvVar:     "DB_HOST",
+				EnvAliases: []string{"OLD_DB_HOST", "LEGACY_HOST"},
+			},
+		},
+		{
+			name: "flag scope persistent",
+			tag:  "cli:foo,flag-scope:persistent",
+			expected: conf.Tag{
+				CLIFlag:    "foo",
+				IsCLIPFlag: true,
+			},
+		},
+		{
+			name: "flag scope local",
+			tag:  "cli:foo,flag-scope:local",
+			expected: conf.Tag{
+				CLIFlag: "foo",
+			},
+		},
+		{
+			name: "hidden flag",
+			tag:  "cli:foo,hidden",
+			expected: conf.Tag{
+				CLIFlag: "foo",
+				Hidden:  true,
+			},
+		},
+		{
+			name: "deprecated flag",
+			tag:  "cli:old-name,deprecated:use --new-name instead",
+			expected: conf.Tag{
+				CLIFlag:    "old-name",
+				Deprecated: "use --new-name instead",
+			},
+		},
+		{
+			name: "mutex group",
+			tag:  "cli:json,mutex-group:output",
+			expected: conf.Tag{
+				CLIFlag:    "json",
+				MutexGroup: "output",
+			},
+		},
+		{
+			name: "positional arg",
+			tag:  "arg:1,required",
+			expected: conf.Tag{
+				IsArg:    true,
+				ArgIndex: 1,
+				Required: true,
+			},
+		},
+		{
+			name: "toml key",
+			tag:  "env:DB_HOST,toml:host",
+			expected: conf.Tag{
+				EnvVar:  "DB_HOST",
+				TOMLKey: "host",
+			},
+		},
+		{
+			name: "yaml key",
+			tag:  "env:DB_HOST,yaml:host",
+			expected: conf.Tag{
+				EnvVar:  "DB_HOST",
+				YAMLKey: "host",
+			},
+		},
+		{
+			name: "vault key",
+			tag:  "env:DB_PASS,vault:password",
+			expected: conf.Tag{
+				EnvVar:   "DB_PASS",
+				VaultKey: "password",
+			},
+		},
+		{
+			name: "expand strict implies expand",
+			tag:  "env:DSN,expand-strict",
+			expected: conf.Tag{
+				EnvVar:       "DSN",
+				Expand:       true,
+				ExpandStrict: true,
+			},
+		},
+		{
+			name: "cli group",
+			tag:  "env:DB_HOST,cli:db-host,group:Database",
+			expected: conf.Tag{
+				EnvVar:  "DB_HOST",
+				CLIFlag: "db-host",
+				Group:   "Database",
+			},
+		},
 		{
 			name: "env and required only",
 			tag:  "env:FOO_BAR,required",
@@ -98,7 +208,7 @@ func TestParseTag_Success(t *testing.T) {
 		},
 		{
 			name: "all settings",
-			tag:  "env:FOO_BAR,pstore:xy/z/key,default:XYZ,no-print,mask,no-prefix,required,cli:foo,cli-s:f,cli-u:some usage",
+			tag:  "env:FOO_BAR,pstore:xy/z/key,default:XYZ,no-print,mask,no-prefix,cli:foo,cli-s:f,cli-u:some usage",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				PStoreVar: "xy/z/key",
@@ -109,72 +219,72 @@ func TestParseTag_Success(t *testing.T) {
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
 		{
 			name: "all settings with leading space",
-			tag:  "  env:FOO_BAR, default:XYZ, no-print,mask, no-prefix,    required",
+			tag:  "  env:FOO_BAR, default:XYZ, no-print,mask, no-prefix",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				Default:   "XYZ",
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
 		{
 			name: "env with value that has a leading space",
-			tag:  "  env:      FOO_BAR, default:XYZ, no-print,mask, no-prefix,    required",
+			tag:  "  env:      FOO_BAR, default:XYZ, no-print,mask, no-prefix",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				Default:   "XYZ",
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
 		{
 			name: "default map",
-			tag:  "env:FOO_BAR,default:map(keyA|valueA;keyB|valueB),no-print,mask,no-prefix,required",
+			tag:  "env:FOO_BAR,default:map(keyA|valueA;keyB|valueB),no-print,mask,no-prefix",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				Default:   "keyA:valueA,keyB:valueB",
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
 		{
 			name: "default map spaces are not manipulated",
-			tag:  "env:FOO_BAR,default:map(keyA|valueA;   keyB|valueB),no-print,mask,no-prefix,required",
+			tag:  "env:FOO_BAR,default:map(keyA|valueA;   keyB|valueB),no-print,mask,no-prefix",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				Default:   "keyA:valueA,   keyB:valueB",
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
 		{
 			name: "default list",
-			tag:  "env:FOO_BAR,default:list(a;b;c;d),no-print,mask,no-prefix,required",
+			tag:  "env:FOO_BAR,default:list(a;b;c;d),no-print,mask,no-prefix",
 			expected: conf.Tag{
 				EnvVar:    "FOO_BAR",
 				Default:   "a,b,c,d",
 				IsDefault: true,
 				NoPrint:   true,
 				NoPrefix:  true,
-				Required:  true,
+				Required:  false,
 				Mask:      true,
 			},
 		},
@@ -208,6 +318,106 @@ func TestParseTag_Success(t *testing.T) {
 				IsDefault: true,
 			},
 		},
+		{
+			name: "json key",
+			tag:  "env:FOO_BAR,json:fooBar",
+			expected: conf.Tag{
+				EnvVar:  "FOO_BAR",
+				JSONKey: "fooBar",
+			},
+		},
+		{
+			name: "env and from-file",
+			tag:  "env:DB_PASS,from-file",
+			expected: conf.Tag{
+				EnvVar:   "DB_PASS",
+				FromFile: true,
+			},
+		},
+		{
+			name: "struct prefix",
+			tag:  "prefix:PRIMARY_DB",
+			expected: conf.Tag{
+				Prefix: "PRIMARY_DB",
+			},
+		},
+		{
+			name: "custom slice delimiter",
+			tag:  "env:PATHS,delim:;",
+			expected: conf.Tag{
+				EnvVar:    "PATHS",
+				Delimiter: ";",
+			},
+		},
+		{
+			name: "custom map separators",
+			tag:  "env:SERVERS,map-kv-sep:=,map-pair-sep:;",
+			expected: conf.Tag{
+				EnvVar:     "SERVERS",
+				MapPairSep: ";",
+				MapKVSep:   "=",
+			},
+		},
+		{
+			name: "numeric bounds",
+			tag:  "env:PORT,min:1,max:65535",
+			expected: conf.Tag{
+				EnvVar: "PORT",
+				Min:    "1",
+				Max:    "65535",
+			},
+		},
+		{
+			name: "oneof allowed set",
+			tag:  "env:LOG_LEVEL,oneof:debug|info|warn|error,default:info,oneof-ci",
+			expected: conf.Tag{
+				EnvVar:    "LOG_LEVEL",
+				OneOf:     []string{"debug", "info", "warn", "error"},
+				OneOfCI:   true,
+				Default:   "info",
+				IsDefault: true,
+			},
+		},
+		{
+			name:     "scope-cmds",
+			tag:      "cli:port,scope-cmds:serve| migrate",
+			expected: conf.Tag{CLIFlag: "port", ScopeCmds: []string{"serve", "migrate"}},
+		},
+		{
+			name:     "required in some environments",
+			tag:      "env:SENTRY_DSN,required-env:production| staging",
+			expected: conf.Tag{EnvVar: "SENTRY_DSN", RequiredEnv: []string{"production", "staging"}},
+		},
+		{
+			name:     "format of a secret",
+			tag:      "env:API_KEY,mask,regex:^[0-9a-f]+$,len:32",
+			expected: conf.Tag{EnvVar: "API_KEY", Mask: true, Regex: "^[0-9a-f]+$", Len: "32"},
+		},
+		{
+			name:     "indexed slice",
+			tag:      "env:HOSTS,indexed",
+			expected: conf.Tag{EnvVar: "HOSTS", Indexed: true},
+		},
+		{
+			name:     "doc is last and may contain commas",
+			tag:      "env:WORKERS,default:4, doc: Number of workers, tune for the CPU: 4 is fine",
+			expected: conf.Tag{EnvVar: "WORKERS", Default: "4", IsDefault: true, Doc: "Number of workers, tune for the CPU: 4 is fine"},
+		},
+		{
+			name:     "doc only",
+			tag:      "doc:just docs",
+			expected: conf.Tag{Doc: "just docs"},
+		},
+		{
+			name:     "forced base",
+			tag:      "env:PERMS,base:8",
+			expected: conf.Tag{EnvVar: "PERMS", Base: 8},
+		},
+		{
+			name:     "bool int",
+			tag:      "env:VERBOSE,bool-int",
+			expected: conf.Tag{EnvVar: "VERBOSE", BoolInt: true},
+		},
 	}
 
 	for _, tt := range tests {
@@ -229,11 +439,61 @@ func TestParseTag_Failures(t *testing.T) {
 			tag:  "env:FOO_BAR,default:,required",
 			msg:  `tag ("default") missing a value`,
 		},
+		{
+			name: "required and default together",
+			tag:  "env:FOO_BAR,default:XYZ,required",
+			msg:  "tag has both required and default (mutually exclusive)",
+		},
+		{
+			name: "unsupported flag scope",
+			tag:  "cli:foo,flag-scope:global",
+			msg:  `tag (flag-scope) unsupported value "global"`,
+		},
+		{
+			name: "invalid arg index",
+			tag:  "arg:-1",
+			msg:  `tag (arg) invalid index "-1"`,
+		},
+		{
+			name: "invalid regex",
+			tag:  "env:API_KEY,regex:[a-",
+			msg:  `tag (regex) invalid pattern "[a-"`,
+		},
+		{
+			name: "invalid len",
+			tag:  "env:API_KEY,len:32-16",
+			msg:  `tag (len) invalid value "32-16"`,
+		},
+		{
+			name: "invalid base",
+			tag:  "env:PERMS,base:1",
+			msg:  `tag (base) invalid value "1"`,
+		},
 		{
 			name: "env without a value",
 			tag:  "env:,default:SomeValue,required",
 			msg:  `tag ("env") missing a value`,
 		},
+		{
+			name: "unsupported encoding",
+			tag:  "env:KEY,encoding:base32",
+			msg:  `tag (encoding) unsupported value "base32"`,
+		},
+		{
+			name: "required-if without a condition",
+			tag:  "env:TLS_CERT,required-if:TLS_ENABLED",
+			msg:  `tag ("required-if") must be in the form KEY=VALUE`,
+		},
+		{
+			name: "regex with a comma",
+			tag:  "env:API_KEY,regex:^[a-f]{32,64}$",
+			msg:  "tag (regex) value can not contain a comma",
+		},
+		{
+			name: "deprecated with a comma",
+			tag:  "cli:old-name,deprecated:use --new-name, it is faster",
+			msg:  "tag (deprecated) value can not contain a comma",
+		},
 	}
 
 	for _, tt := range tests {
@@ -244,3 +504,100 @@ func TestParseTag_Failures(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTagStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		msg  string
+	}{
+		{
+			name: "misspelled flag",
+			tag:  "env:FOO_BAR,requred",
+			msg:  `unknown tag key "requred"`,
+		},
+		{
+			name: "misspelled key with value",
+			tag:  "env:FOO_BAR,defalt:foo",
+			msg:  `unknown tag key "defalt"`,
+		},
+		{
+			name: "mask typed as masked",
+			tag:  "env:FOO_BAR,masked",
+			msg:  `unknown tag key "masked"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := conf.ParseTag(tt.tag)
+			require.NoError(t, err, "conf.ParseTag is lenient by default")
+
+			_, err = conf.ParseTagStrict(tt.tag)
+			require.Error(t, err, "conf.ParseTagStrict is expected to fail")
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+
+	result, err := conf.ParseTagStrict("env:FOO_BAR,default:abc,mask,no-print")
+	require.NoError(t, err, "conf.ParseTagStrict is not expected to fail")
+	assert.Equal(t, "FOO_BAR", result.EnvVar)
+}
+
+func TestStrictTags_Fields(t *testing.T) {
+	type MyConfig struct {
+		Pass string `conf:"env:DB_PASS,masked"`
+	}
+
+	conf.StrictTags = true
+	defer func() { conf.StrictTags = false }()
+
+	var config MyConfig
+	_, err := conf.Fields(&config)
+	require.Error(t, err, "conf.Fields is expected to fail")
+	assert.Contains(t, err.Error(), `parseTag failed (Pass): unknown tag key "masked"`)
+}
+
+func TestRegisterTagPreset(t *testing.T) {
+	require.NoError(t, conf.RegisterTagPreset("test-secret", "required,mask,no-print"))
+	require.NoError(t, conf.RegisterTagPreset("test-port", "default:8080,cli:port"))
+	require.NoError(t, conf.RegisterTagPreset("test-nested", "preset:test-port,no-prefix"))
+
+	tag, err := conf.ParseTag("env:API_KEY,preset:test-secret")
+	require.NoError(t, err, "conf.ParseTag is not expected to fail")
+	assert.Equal(t, conf.Tag{EnvVar: "API_KEY", Required: true, Mask: true, NoPrint: true}, tag)
+
+	tag, err = conf.ParseTag("preset:test-nested,env:PORT,default:9090")
+	require.NoError(t, err, "conf.ParseTag is not expected to fail")
+	expected := conf.Tag{EnvVar: "PORT", CLIFlag: "port", Default: "9090", IsDefault: true, NoPrefix: true}
+	assert.Equal(t, expected, tag)
+
+	_, err = conf.ParseTag("env:API_KEY,preset:test-missing")
+	require.Error(t, err, "conf.ParseTag is expected to fail")
+	assert.Contains(t, err.Error(), `tag (preset) unknown preset "test-missing"`)
+
+	err = conf.RegisterTagPreset("test-invalid", "required,default:x")
+	require.Error(t, err, "conf.RegisterTagPreset is expected to fail")
+	assert.Contains(t, err.Error(), "preset (test-invalid) is not a valid tag")
+
+	err = conf.RegisterTagPreset("", "required")
+	require.Error(t, err, "conf.RegisterTagPreset is expected to fail")
+	assert.Contains(t, err.Error(), "preset name is empty")
+}
+
+func TestRegisterTagPreset_Replace(t *testing.T) {
+	type MyConfig struct {
+		Port int `conf:"env:PORT,preset:test-replace"`
+	}
+
+	require.NoError(t, conf.RegisterTagPreset("test-replace", "default:8080"))
+	var config MyConfig
+	fields, err := conf.Fields(&config)
+	require.NoError(t, err, "conf.Fields is not expected to fail")
+	assert.Equal(t, "8080", fields[0].DefaultValue())
+
+	require.NoError(t, conf.RegisterTagPreset("test-replace", "default:9090"))
+	fields, err = conf.Fields(&config)
+	require.NoError(t, err, "conf.Fields is not expected to fail")
+	assert.Equal(t, "9090", fields[0].DefaultValue(), "the cached layout is dropped")
+}
@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"context"
+	"io"
+
+	"github.com/pelletier/go-toml"
+	"github.com/rsb/failure"
+)
+
+// ProcessTOML decodes a TOML document from r and populates spec from it.
+// Each field is looked up by its toml tag key, falling back to its env name,
+// and the value is processed like ProcessEnv would, with the same defaults
+// and required checks. Fields of nested structs are looked up in the table
+// named after the struct field, see TOMLSource.
+func ProcessTOML(r io.Reader, spec interface{}) error {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return failure.ToConfig(err, "toml.LoadReader failed")
+	}
+
+	if err = process(context.Background(), spec, []Source{TOMLSource(tree.ToMap())}, defaultOptions()); err != nil {
+		return failure.Wrap(err, "process failed")
+	}
+
+	return nil
+}
+
+// TOMLSource resolves fields from decoded TOML tables using Field.TOMLKey,
+// see MapSource for how tables are matched to nested structs.
+func TOMLSource(data map[string]interface{}) Source {
+	return MapSource(data, Field.TOMLKey)
+}
@@ -0,0 +1,42 @@
+package conf_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessTOML_FlatKeysForNestedFields(t *testing.T) {
+	type Database struct {
+		Host string `conf:"env:DB_HOST,required"`
+	}
+
+	type MyConfig struct {
+		DB Database
+	}
+
+	var config MyConfig
+	err := conf.ProcessTOML(strings.NewReader(`DB_HOST = "db.local"`), &config)
+	require.NoError(t, err, "conf.ProcessTOML is not expected to fail")
+	assert.Equal(t, "db.local", config.DB.Host)
+}
+
+func TestProcessTOML_TaggedKeyIsNotLookedUpAtTopLevel(t *testing.T) {
+	type Database struct {
+		Host string `conf:"env:DB_HOST,toml:host"`
+	}
+
+	type MyConfig struct {
+		Host string `conf:"env:APP_HOST,toml:host"`
+		DB   Database
+	}
+
+	var config MyConfig
+	err := conf.ProcessTOML(strings.NewReader(`host = "app.local"`), &config)
+	require.NoError(t, err, "conf.ProcessTOML is not expected to fail")
+	assert.Equal(t, "app.local", config.Host)
+	assert.Empty(t, config.DB.Host)
+}
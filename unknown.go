@@ -0,0 +1,98 @@
+package conf
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rsb/failure"
+)
+
+// UnknownEnvVars returns the variables set in the environment that start
+// with prefix but are not claimed by any field of spec, which catches typos
+// like APP_DB_HSOT that would otherwise leave DB_HOST at its default. Env
+// aliases, the _FILE companion of from-file fields and the numbered variables
+// of indexed fields count as claimed. The result is sorted.
+func UnknownEnvVars(spec interface{}, prefix string) ([]string, error) {
+	return unknownEnvVars(spec, defaultOptions(), prefix)
+}
+
+func unknownEnvVars(spec interface{}, opts options, prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, failure.Config("prefix is empty, every env var would be unknown")
+	}
+
+	fields, err := specFields(spec, opts, prefix)
+	if err != nil {
+		return nil, failure.Wrap(err, "Fields failed")
+	}
+
+	claims := claimEnvVars(fields)
+
+	var result []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, prefix+"_") {
+			continue
+		}
+
+		if _, ok := claims.field(name); ok {
+			continue
+		}
+
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// envClaims are the env vars read by the fields of a spec, see
+// claimEnvVars
+type envClaims struct {
+	names   map[string]Field
+	indexed []Field
+}
+
+// claimEnvVars collects every variable fields read: the env var, its
+// aliases, the _FILE companion of from-file fields and the numbered
+// variables of indexed fields
+func claimEnvVars(fields []Field) envClaims {
+	claims := envClaims{names: map[string]Field{}}
+	for _, field := range fields {
+		if field.Tag.EnvVar == "-" {
+			continue
+		}
+
+		claims.names[field.EnvVariable()] = field
+		for _, alias := range field.EnvAliases() {
+			claims.names[alias] = field
+		}
+
+		if field.IsFromFile() {
+			claims.names[field.FileEnvVariable()] = field
+		}
+
+		if field.IsIndexed() {
+			claims.indexed = append(claims.indexed, field)
+		}
+	}
+
+	return claims
+}
+
+// field returns the field that reads the variable name
+func (c envClaims) field(name string) (Field, bool) {
+	if field, ok := c.names[name]; ok {
+		return field, true
+	}
+
+	for _, field := range c.indexed {
+		p := field.EnvVariable() + "_"
+		if len(name) > len(p) && strings.HasPrefix(name, p) && isDigits(name[len(p):]) {
+			return field, true
+		}
+	}
+
+	return Field{}, false
+}
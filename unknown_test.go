@@ -0,0 +1,60 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownEnvVars(t *testing.T) {
+	type DBConfig struct {
+		Host string `conf:"env:HOST,default:localhost"`
+	}
+	type MyConfig struct {
+		DB       DBConfig `conf:"prefix:DB"`
+		Port     int      `conf:"env:PORT,env-alias:LISTEN_PORT"`
+		Password string   `conf:"env:PASSWORD,from-file"`
+		Hosts    []string `conf:"env:HOSTS,indexed"`
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_DB_HSOT", "db.internal")
+	setenv(t, "APP_LISTEN_PORT", "8080")
+	setenv(t, "APP_PASSWORD_FILE", "/run/secrets/password")
+	setenv(t, "APP_HOSTS_0", "a")
+	setenv(t, "APP_HOSTS_1", "b")
+	setenv(t, "APP_HOSTS_X", "c")
+	setenv(t, "APP_EXTRA", "x")
+	setenv(t, "OTHER_VAR", "y")
+
+	names, err := conf.UnknownEnvVars(&MyConfig{}, "APP")
+	require.NoError(t, err, "conf.UnknownEnvVars is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HSOT", "APP_EXTRA", "APP_HOSTS_X"}, names)
+
+	_, err = conf.UnknownEnvVars(&MyConfig{}, "")
+	require.Error(t, err, "conf.UnknownEnvVars is expected to fail without a prefix")
+	assert.Contains(t, err.Error(), "prefix is empty")
+	os.Clearenv()
+}
+
+func TestConfig_UnknownEnvVars(t *testing.T) {
+	type MyConfig struct {
+		DBHost string
+	}
+
+	os.Clearenv()
+	setenv(t, "APP_DB_HOST", "localhost")
+	setenv(t, "APP_DB_HSOT", "localhost")
+
+	var config MyConfig
+	c := conf.NewConfig(&config, "APP")
+	c.NameCase = conf.NameCaseUpperSnake
+
+	names, err := c.UnknownEnvVars()
+	require.NoError(t, err, "c.UnknownEnvVars is not expected to fail")
+	assert.Equal(t, []string{"APP_DB_HSOT"}, names)
+	os.Clearenv()
+}
@@ -0,0 +1,81 @@
+package conf
+
+import (
+	"reflect"
+
+	"github.com/rsb/failure"
+)
+
+// Validator is implemented by config structs that want to check invariants
+// across fields. Validate is called by ProcessEnv, ProcessCLI and Process
+// once every field has been populated.
+type Validator interface {
+	Validate() error
+}
+
+// ValidatorFrom returns the Validator implemented by the value or its
+// address, or nil when it has none
+func ValidatorFrom(field reflect.Value) (v Validator) {
+	interfaceFrom(field, func(i interface{}, ok *bool) { v, *ok = i.(Validator) })
+	return v
+}
+
+// Validate walks spec the same way Fields does and calls Validate on every
+// struct that implements Validator, nested structs first and spec last.
+// An anonymous embedded struct is not called on its own when its parent also
+// implements Validator, since the parent either promotes that same method or
+// deliberately overrides it.
+func Validate(spec interface{}) error {
+	s := reflect.ValueOf(spec)
+	if s.Kind() != reflect.Ptr {
+		return InvalidSpecFailure
+	}
+
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return InvalidSpecFailure
+	}
+
+	return validateStruct(s, false)
+}
+
+func validateStruct(s reflect.Value, skipSelf bool) error {
+	parentValidates := ValidatorFrom(s) != nil
+	specType := s.Type()
+
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		ftype := specType.Field(i)
+
+		if !f.CanSet() || ftype.Tag.Get("conf") == "-" {
+			continue
+		}
+
+		for f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				break
+			}
+			f = f.Elem()
+		}
+
+		if f.Kind() != reflect.Struct || isValueType(f) {
+			continue
+		}
+
+		if err := validateStruct(f, ftype.Anonymous && parentValidates); err != nil {
+			return err
+		}
+	}
+
+	if skipSelf {
+		return nil
+	}
+
+	if v := ValidatorFrom(s); v != nil {
+		if err := v.Validate(); err != nil {
+			return failure.Wrap(err, "Validate failed (%s)", specType.Name())
+		}
+	}
+
+	return nil
+}
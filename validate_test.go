@@ -0,0 +1,96 @@
+package conf_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ValidatedDB struct {
+	Host string `conf:"env:VDB_HOST"`
+	Port int    `conf:"env:VDB_PORT,default:5432"`
+}
+
+func (db *ValidatedDB) Validate() error {
+	if db.Host == "" {
+		return failure.Validation("host is empty")
+	}
+
+	if db.Port < 1 || db.Port > 65535 {
+		return failure.Validation("port (%d) out of range", db.Port)
+	}
+
+	return nil
+}
+
+type ValidatedConfig struct {
+	ValidatedDB
+	Name string `conf:"env:VAPP_NAME"`
+}
+
+type OverrideConfig struct {
+	ValidatedDB
+	calls int
+}
+
+func (c *OverrideConfig) Validate() error {
+	c.calls++
+	return nil
+}
+
+type NestedConfig struct {
+	Primary ValidatedDB `conf:"env:PRIMARY"`
+	Name    string      `conf:"env:VAPP_NAME"`
+}
+
+func TestProcessEnv_ValidateSuccess(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "VDB_HOST", "localhost")
+
+	var config ValidatedConfig
+	err := conf.ProcessEnv(&config)
+	require.NoError(t, err, "conf.ProcessEnv is not expected to fail")
+	assert.Equal(t, "localhost", config.Host)
+	os.Clearenv()
+}
+
+func TestProcessEnv_ValidateFailure(t *testing.T) {
+	os.Clearenv()
+	setenv(t, "VDB_HOST", "localhost")
+	setenv(t, "VDB_PORT", "70000")
+
+	var config ValidatedConfig
+	err := conf.ProcessEnv(&config)
+	require.Error(t, err, "conf.ProcessEnv is expected to fail")
+	assert.Contains(t, err.Error(), "Validate failed (ValidatedConfig)")
+	assert.Contains(t, err.Error(), "port (70000) out of range")
+	assert.True(t, failure.IsValidation(err))
+	os.Clearenv()
+}
+
+func TestValidate_NestedStruct(t *testing.T) {
+	var config NestedConfig
+
+	err := conf.Validate(&config)
+	require.Error(t, err, "conf.Validate is expected to fail")
+	assert.Contains(t, err.Error(), "Validate failed (ValidatedDB)")
+}
+
+func TestValidate_ParentOverridesEmbedded(t *testing.T) {
+	var config OverrideConfig
+
+	err := conf.Validate(&config)
+	require.NoError(t, err, "conf.Validate is not expected to fail")
+	assert.Equal(t, 1, config.calls)
+}
+
+func TestValidate_InvalidSpec(t *testing.T) {
+	var config ValidatedConfig
+
+	err := conf.Validate(config)
+	assert.Equal(t, conf.InvalidSpecFailure, err)
+}
@@ -0,0 +1,64 @@
+// Package vault loads conf specs from HashiCorp Vault. It is its own module
+// so the Vault client and its dependencies are only pulled in by programs
+// that use it.
+package vault
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/rsb/conf"
+	"github.com/rsb/failure"
+)
+
+// Process populates spec from the Vault KV v2 secret at path in the engine
+// mounted at mount. Fields are looked up in the data of the secret by
+// Field.VaultKey, see conf.MapSource, and go through the same processing as
+// conf.ProcessEnv, including defaults and required checks.
+func Process(client *api.Client, mount, path string, spec interface{}) error {
+	data, err := read(client, mount, path)
+	if err != nil {
+		return err
+	}
+
+	src := conf.MapSource(data, conf.Field.VaultKey)
+	if err = conf.Process(context.Background(), spec, src); err != nil {
+		return failure.Wrap(err, "conf.Process failed")
+	}
+
+	return nil
+}
+
+// SecretSource reads the KV v2 secret at path in the engine mounted at mount
+// once and serves its keys as a conf.SecretSource, for use with
+// conf.ProcessWithSource. Values that are not strings are formatted with
+// fmt.Sprint.
+func SecretSource(client *api.Client, mount, path string) (conf.SecretSource, error) {
+	data, err := read(client, mount, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf.SecretSourceFunc(func(key string) (string, bool, error) {
+		item, ok := data[key]
+		if !ok {
+			return "", false, nil
+		}
+
+		if value, ok := item.(string); ok {
+			return value, true, nil
+		}
+
+		return fmt.Sprint(item), true, nil
+	}), nil
+}
+
+func read(client *api.Client, mount, path string) (map[string]interface{}, error) {
+	secret, err := client.KVv2(mount).Get(context.Background(), path)
+	if err != nil {
+		return nil, failure.ToSystem(err, "vault KVv2.Get failed (%s/%s)", mount, path)
+	}
+
+	return secret.Data, nil
+}
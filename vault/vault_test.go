@@ -0,0 +1,99 @@
+package vault_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/rsb/conf"
+	"github.com/rsb/conf/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vaultServer serves KV v2 secrets, keyed by the full request path, as JSON
+// bodies
+func vaultServer(t *testing.T, secrets map[string]string) *api.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := api.DefaultConfig()
+	cfg.Address = server.URL
+	cfg.MaxRetries = 0
+	client, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	client.SetToken("test-token")
+
+	return client
+}
+
+func TestProcess(t *testing.T) {
+	type MyConfig struct {
+		User  string   `conf:"env:DB_USER,required"`
+		Pass  string   `conf:"env:DB_PASS,vault:password,mask"`
+		Port  int      `conf:"env:DB_PORT,default:5432"`
+		Pool  int      `conf:"env:DB_POOL"`
+		Hosts []string `conf:"env:DB_HOSTS"`
+	}
+
+	client := vaultServer(t, map[string]string{
+		"/v1/secret/data/app/db": `{"data": {
+			"data": {"DB_USER": "admin", "password": "s3cret", "DB_POOL": 20, "DB_HOSTS": ["a", "b"]},
+			"metadata": {"version": 3}
+		}}`,
+		"/v1/secret/data/app/empty": `{"data": {"data": {}, "metadata": {"version": 1}}}`,
+	})
+
+	var config MyConfig
+	err := vault.Process(client, "secret", "app/db", &config)
+	require.NoError(t, err, "vault.Process is not expected to fail")
+
+	expected := MyConfig{User: "admin", Pass: "s3cret", Port: 5432, Pool: 20, Hosts: []string{"a", "b"}}
+	assert.Equal(t, expected, config)
+
+	err = vault.Process(client, "secret", "app/empty", &MyConfig{})
+	require.Error(t, err, "vault.Process is expected to fail")
+	assert.Contains(t, err.Error(), "required key (User,DB_USER) missing value")
+
+	err = vault.Process(client, "secret", "app/missing", &MyConfig{})
+	require.Error(t, err, "vault.Process is expected to fail")
+	assert.Contains(t, err.Error(), "vault KVv2.Get failed (secret/app/missing)")
+}
+
+func TestSecretSource(t *testing.T) {
+	type MyConfig struct {
+		User string `conf:"env:DB_USER"`
+		Pass string `conf:"env:DB_PASS,secret:password,required"`
+		Pin  int    `conf:"env:DB_PIN,secret:pin"`
+	}
+
+	client := vaultServer(t, map[string]string{
+		"/v1/secret/data/app/db": `{"data": {
+			"data": {"DB_USER": "admin", "password": "s3cret", "pin": 1234},
+			"metadata": {"version": 3}
+		}}`,
+	})
+
+	src, err := vault.SecretSource(client, "secret", "app/db")
+	require.NoError(t, err, "vault.SecretSource is not expected to fail")
+
+	var config MyConfig
+	err = conf.ProcessWithSource(src, &config)
+	require.NoError(t, err, "conf.ProcessWithSource is not expected to fail")
+	assert.Equal(t, MyConfig{Pass: "s3cret", Pin: 1234}, config)
+
+	_, err = vault.SecretSource(client, "secret", "app/missing")
+	require.Error(t, err, "vault.SecretSource is expected to fail")
+	assert.Contains(t, err.Error(), "vault KVv2.Get failed (secret/app/missing)")
+}
@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"context"
+	"io"
+
+	"github.com/rsb/failure"
+	"gopkg.in/yaml.v3"
+)
+
+// ProcessYAML decodes a YAML mapping from r and populates spec from it. Each
+// field is looked up by its yaml tag key, falling back to its env name, and
+// processed like ProcessEnv would. Nested mappings are matched to nested
+// structs the same way ProcessTOML matches tables, and sequences populate
+// slice fields.
+func ProcessYAML(r io.Reader, spec interface{}) error {
+	var data map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&data); err != nil && err != io.EOF {
+		return failure.ToConfig(err, "yaml.Decode failed")
+	}
+
+	if err := process(context.Background(), spec, []Source{YAMLSource(data)}, defaultOptions()); err != nil {
+		return failure.Wrap(err, "process failed")
+	}
+
+	return nil
+}
+
+// YAMLSource resolves fields from a decoded YAML mapping using Field.YAMLKey,
+// nested structs are looked up like TOMLSource does.
+func YAMLSource(data map[string]interface{}) Source {
+	return MapSource(data, Field.YAMLKey)
+}